@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/patch"
+	"github.com/spf13/cobra"
+)
+
+func newApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <patch>...",
+		Short: "Apply unified diffs to the files of a directory",
+		Long: `apply parses unified diffs (such as the answer of an LLM to treecat output)
+and applies them to the files under the directory, reporting the result of
+each hunk. Use "-" to read from stdin. New, deleted and renamed files are
+supported. Hunks are searched for near their line numbers, ignoring whitespace
+differences and up to --fuzz context lines if needed.
+If any hunk fails, no files are written unless --partial is given.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runApply,
+	}
+
+	cmd.Flags().StringP("dir", "d", ".", "Directory the paths in the diffs are relative to")
+	cmd.Flags().IntP("strip", "p", -1, "Leading path components to strip (-1 strips the a/ and b/ prefixes of git diffs)")
+	cmd.Flags().Int("fuzz", 2, "Maximum context lines to ignore at each end of a hunk")
+	cmd.Flags().Bool("dry-run", false, "Show the result without writing files")
+	cmd.Flags().Bool("partial", false, "Write the hunks that applied even if others failed")
+	cmd.Flags().String("encoding-map", "", "Encoding rules by glob pattern or extension, first match wins (e.g., legacy/**/*.txt:shift_jis,log:euc-jp)")
+	cmd.Flags().String("default-encoding", "", "Encoding of files matching no --encoding-map rule (default utf-8)")
+	cmd.Flags().Bool("detect-encoding", false, "Detect the encoding of files not in --encoding-map")
+
+	return cmd
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	dir, _ := cmd.Flags().GetString("dir")
+	strip, _ := cmd.Flags().GetInt("strip")
+	fuzz, _ := cmd.Flags().GetInt("fuzz")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	partial, _ := cmd.Flags().GetBool("partial")
+	encodingMapStr, _ := cmd.Flags().GetString("encoding-map")
+	defaultEncoding, _ := cmd.Flags().GetString("default-encoding")
+	detectEncoding, _ := cmd.Flags().GetBool("detect-encoding")
+
+	if fuzz < 0 {
+		return fmt.Errorf("invalid --fuzz: %d", fuzz)
+	}
+
+	// Parse encoding rules, which should be the ones used for the output
+	// the diffs were written against
+	if defaultEncoding != "" && detectEncoding {
+		return fmt.Errorf("--default-encoding cannot be used with --detect-encoding")
+	}
+	encodingRules, err := encoding.ParseRules(encodingMapStr, defaultEncoding)
+	if err != nil {
+		return fmt.Errorf("failed to parse encoding map: %w", err)
+	}
+
+	// Read and parse patches
+	var diffs []*patch.FileDiff
+	for _, path := range args {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read patch: %w", err)
+		}
+
+		fileDiffs, err := patch.Parse(data, strip)
+		if err != nil {
+			return fmt.Errorf("failed to parse patch %s: %w", path, err)
+		}
+		diffs = append(diffs, fileDiffs...)
+	}
+
+	result := patch.Apply(diffs, dir, patch.Options{
+		Fuzz:           fuzz,
+		EncodingRules:  encodingRules,
+		DetectEncoding: detectEncoding,
+	})
+	writeApplyReport(cmd.OutOrStdout(), result)
+
+	failed := result.Failed()
+	if dryRun {
+		fmt.Fprintln(cmd.OutOrStdout(), "Dry run: no files were written")
+	} else if failed == 0 || partial {
+		if err := result.Write(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		if partial || dryRun {
+			return fmt.Errorf("%d hunk(s) failed", failed)
+		}
+		return fmt.Errorf("%d hunk(s) failed; no files were written (use --partial to write the hunks that applied)", failed)
+	}
+	return nil
+}
+
+// writeApplyReport lists each file diff and the result of each of its hunks.
+func writeApplyReport(w io.Writer, result *patch.Result) {
+	for _, file := range result.Files {
+		diff := file.Diff
+		if file.Op == patch.OpRename {
+			fmt.Fprintf(w, "%-6s  %s -> %s\n", file.Op, diff.OldPath, diff.NewPath)
+		} else {
+			fmt.Fprintf(w, "%-6s  %s\n", file.Op, diff.Path())
+		}
+
+		if file.Err != nil {
+			fmt.Fprintf(w, "  FAILED: %v\n", file.Err)
+			continue
+		}
+		for i, hunk := range file.Hunks {
+			if !hunk.Applied {
+				fmt.Fprintf(w, "  hunk %d FAILED: %s (%s)\n", i+1, hunk.Reason, hunk.Hunk.Header())
+				continue
+			}
+
+			var notes []string
+			if hunk.Offset != 0 {
+				notes = append(notes, fmt.Sprintf("offset %+d", hunk.Offset))
+			}
+			if hunk.Fuzz > 0 {
+				notes = append(notes, fmt.Sprintf("fuzz %d", hunk.Fuzz))
+			}
+			if hunk.Whitespace {
+				notes = append(notes, "whitespace ignored")
+			}
+			if len(notes) > 0 {
+				fmt.Fprintf(w, "  hunk %d applied at line %d (%s)\n", i+1, hunk.Line, strings.Join(notes, ", "))
+			} else {
+				fmt.Fprintf(w, "  hunk %d applied at line %d\n", i+1, hunk.Line)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestIntegration_Apply(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(1)\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create main.go: %v", err)
+	}
+
+	// An LLM answer with a diff whose indentation differs from the file
+	patchPath := filepath.Join(tmpDir, "answer.md")
+	answer := "Change the output:\n\n```diff\n" +
+		"--- a/main.go\n+++ b/main.go\n" +
+		"@@ -3,3 +3,3 @@\n func main() {\n-    println(1)\n+\tprintln(2)\n }\n" +
+		"--- /dev/null\n+++ b/util.go\n@@ -0,0 +1 @@\n+package main\n" +
+		"```\n"
+	if err := os.WriteFile(patchPath, []byte(answer), 0644); err != nil {
+		t.Fatalf("Failed to create patch: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"apply", patchPath, "-d", srcDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	expectedReport := "modify  main.go\n" +
+		"  hunk 1 applied at line 3 (whitespace ignored)\n" +
+		"create  util.go\n" +
+		"  hunk 1 applied at line 1\n"
+	if stdout.String() != expectedReport {
+		t.Errorf("Expected report:\n%s\nGot:\n%s", expectedReport, stdout.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(srcDir, "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to read main.go: %v", err)
+	}
+	if string(content) != "package main\n\nfunc main() {\n\tprintln(2)\n}\n" {
+		t.Errorf("Unexpected main.go: %q", string(content))
+	}
+	if _, err := os.Stat(filepath.Join(srcDir, "util.go")); err != nil {
+		t.Errorf("Expected util.go to be created: %v", err)
+	}
+}
+
+func TestIntegration_ApplyFailedHunk(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(filePath, []byte("1\n2\n3\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+
+	patchPath := filepath.Join(tmpDir, "fix.diff")
+	diff := "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-1\n+one\n@@ -3 +3 @@\n-missing\n+three\n"
+	if err := os.WriteFile(patchPath, []byte(diff), 0644); err != nil {
+		t.Fatalf("Failed to create patch: %v", err)
+	}
+
+	// Without --partial nothing is written
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"apply", patchPath, "-d", tmpDir})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "1 hunk(s) failed; no files were written") {
+		t.Fatalf("Expected failed hunk error, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "  hunk 2 FAILED: context not found (@@ -3,1 +3,1 @@)\n") {
+		t.Errorf("Expected failed hunk in report, got:\n%s", stdout.String())
+	}
+	content, _ := os.ReadFile(filePath)
+	if string(content) != "1\n2\n3\n" {
+		t.Errorf("Expected a.txt to be unchanged, got %q", string(content))
+	}
+
+	// With --partial the hunk that applied is written
+	cmd = newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"apply", patchPath, "-d", tmpDir, "--partial"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected error for failed hunk")
+	}
+	content, _ = os.ReadFile(filePath)
+	if string(content) != "one\n2\n3\n" {
+		t.Errorf("Expected first hunk to be applied, got %q", string(content))
+	}
+}
+
+func TestIntegration_ApplyEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+	content, err := japanese.ShiftJIS.NewEncoder().String("こんにちは\n世界\n")
+	if err != nil {
+		t.Fatalf("Failed to encode Shift_JIS: %v", err)
+	}
+	filePath := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+
+	patchPath := filepath.Join(t.TempDir(), "fix.diff")
+	diff := "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n こんにちは\n-世界\n+皆さん\n"
+	if err := os.WriteFile(patchPath, []byte(diff), 0644); err != nil {
+		t.Fatalf("Failed to create patch: %v", err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"apply", patchPath, "-d", tmpDir, "--encoding-map", "txt:shift_jis"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	expected, _ := japanese.ShiftJIS.NewEncoder().String("こんにちは\n皆さん\n")
+	result, _ := os.ReadFile(filePath)
+	if string(result) != expected {
+		t.Errorf("Expected Shift_JIS content %q, got %q", expected, result)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/onozaty/treecat/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Sources of flag values other than config files.
+const (
+	sourceFlag    = "flag"
+	sourceDefault = "default"
+)
+
+// conflictingFlags are the flags that can't be used with each flag. A flag
+// given on the command line also overrides config values of these flags.
+var conflictingFlags = map[string][]string{
+	"format":           {"template"},
+	"template":         {"format"},
+	"default-encoding": {"detect-encoding"},
+	"detect-encoding":  {"default-encoding"},
+	"split-size":       {"split-tokens"},
+	"split-tokens":     {"split-size"},
+}
+
+// pathFlags are the flags whose relative paths in config files are resolved
+// from the directory of the config file. In project-level config files, they
+// must stay within that directory, so that a file in a parent directory can't
+// write or read files elsewhere.
+var pathFlags = map[string]bool{
+	"output":   true,
+	"template": true,
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect settings from config files",
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show [directory]",
+		Short: "Show the effective settings and where each came from",
+		Long: `show prints the settings treecat would use for the directory, merged from
+the user-level config file, .treecat.yaml or .treecat.toml in the directory and
+its parents up to the repository root or home directory, and the flags given on
+the command line (which take precedence).`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runConfigShow,
+	}
+	addRootFlags(showCmd.Flags())
+	cmd.AddCommand(showCmd)
+
+	return cmd
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	targetDir := "."
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	sources, err := applyConfig(cmd.Flags(), targetDir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", flag.Name, formatFlagValue(flag), sources[flag.Name])
+	})
+	return w.Flush()
+}
+
+// formatFlagValue formats the value of a flag for config show.
+func formatFlagValue(flag *pflag.Flag) string {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return "[" + strings.Join(slice.GetSlice(), ", ") + "]"
+	}
+	if value := flag.Value.String(); value != "" {
+		return value
+	}
+	return `""`
+}
+
+// applyConfig sets the flags not given on the command line from the config
+// files discovered for the target directory, and returns the source of the
+// value of each flag: "flag", "default" or the path of a config file.
+func applyConfig(flags *pflag.FlagSet, targetDir string) (map[string]string, error) {
+	files, err := config.Discover(targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	sources := map[string]string{}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed {
+			sources[flag.Name] = sourceFlag
+		} else {
+			sources[flag.Name] = sourceDefault
+		}
+	})
+
+	settings := config.Merge(files)
+	precedence := make(map[string]int, len(files))
+	for i, file := range files {
+		precedence[file.Path] = i
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		setting := settings[name]
+		flag := flags.Lookup(name)
+		if flag == nil || name == "help" || name == "version" {
+			return nil, fmt.Errorf("unknown setting %s in %s", name, setting.Source)
+		}
+		if overriddenByFlag(name, sources) || overriddenBySetting(name, settings, precedence) {
+			continue
+		}
+		if err := setFlag(flags, flag, setting); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in %s: %w", name, setting.Source, err)
+		}
+		sources[name] = setting.Source
+	}
+
+	return sources, nil
+}
+
+// overriddenByFlag reports whether the flag or one it conflicts with was
+// given on the command line.
+func overriddenByFlag(name string, sources map[string]string) bool {
+	if sources[name] == sourceFlag {
+		return true
+	}
+	for _, conflicting := range conflictingFlags[name] {
+		if sources[conflicting] == sourceFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// overriddenBySetting reports whether a flag it conflicts with is set by a
+// config file of higher precedence, e.g., format in the repository root is
+// ignored when a subdirectory sets template. Conflicting settings of the same
+// file are left to the validation of the flags.
+func overriddenBySetting(name string, settings map[string]config.Setting, precedence map[string]int) bool {
+	source := precedence[settings[name].Source]
+	for _, conflicting := range conflictingFlags[name] {
+		if setting, ok := settings[conflicting]; ok && precedence[setting.Source] > source {
+			return true
+		}
+	}
+	return false
+}
+
+// setFlag sets a flag from a config setting as if it was given on the
+// command line. Lists set slice flags element by element, and are joined
+// with commas for string flags (e.g., encoding-map rules).
+func setFlag(flags *pflag.FlagSet, flag *pflag.Flag, setting config.Setting) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok && setting.List {
+		if err := slice.Replace(setting.Values); err != nil {
+			return err
+		}
+		flag.Changed = true
+		return nil
+	}
+	if setting.List && flag.Value.Type() != "string" {
+		return fmt.Errorf("expected a single value")
+	}
+
+	value := strings.Join(setting.Values, ",")
+	if pathFlags[flag.Name] && value != "" {
+		if !setting.User {
+			if filepath.IsAbs(value) {
+				return fmt.Errorf("absolute paths are only allowed in the user config file")
+			}
+			if rel := filepath.Clean(value); rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("path must be within the directory of the config file")
+			}
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(filepath.Dir(setting.Source), value)
+		}
+	}
+	return flags.Set(flag.Name, value)
+}
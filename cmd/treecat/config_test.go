@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateUserConfig points the user config directory to an empty directory
+// and returns the path of the treecat directory in it.
+func isolateUserConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	userDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatalf("UserConfigDir failed: %v", err)
+	}
+	return filepath.Join(userDir, "treecat")
+}
+
+func TestIntegration_Config(t *testing.T) {
+	userDir := isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatalf("Failed to create user config directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(userDir, "config.toml"), []byte("format = \"xml\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create user config: %v", err)
+	}
+	config := "exclude:\n  - \"*.log\"\n  - .treecat.yaml\nformat: markdown\noutput: out/bundle.md\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, ".treecat.yaml"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create main.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "app.log"), []byte("log\n"), 0644); err != nil {
+		t.Fatalf("Failed to create app.log: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "out"), 0755); err != nil {
+		t.Fatalf("Failed to create out: %v", err)
+	}
+
+	// The output path is relative to the config file
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--exclude", "*.log,out/**,.treecat.yaml"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(filepath.Join(tmpDir, "out", "bundle.md"))
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	expected := "```\n" + tmpDir + "\n└── main.go\n```\n\n## main.go\n\n```go\npackage main\n```\n"
+	if string(result) != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+func TestIntegration_ConfigOverriddenByFlags(t *testing.T) {
+	isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	templatePath := filepath.Join(tmpDir, "files.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{range .Files}}{{.RelPath}}\n{{end}}"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".treecat.toml"), []byte("template = \"files.tmpl\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.txt")
+
+	// --format on the command line overrides the template in the config,
+	// which can't be used with it
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--format", "plain", "--include", "*.tmpl", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(result), "=== files.tmpl ===\n") {
+		t.Errorf("Expected plain output, got:\n%s", result)
+	}
+}
+
+func TestIntegration_ConfigOverriddenByLayers(t *testing.T) {
+	isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".treecat.yaml"), []byte("format: markdown\nsplit-size: 10000\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	subDir := filepath.Join(tmpDir, "src", "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create sub: %v", err)
+	}
+	subConfig := filepath.Join(subDir, ".treecat.yaml")
+	if err := os.WriteFile(subConfig, []byte("template: files.tmpl\nsplit-tokens: 1000\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "files.tmpl"), []byte("{{range .Files}}{{.RelPath}}\n{{end}}"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	// The settings of the subdirectory override those of the repository
+	// root they can't be used with
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"config", "show", subDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"format":       "plain default",
+		"split-size":   "0 default",
+		"template":     filepath.Join(subDir, "files.tmpl") + " " + subConfig,
+		"split-tokens": "1000 " + subConfig,
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		fields := strings.Fields(line)
+		if value, ok := expected[fields[0]]; ok && strings.Join(fields[1:], " ") != value {
+			t.Errorf("Expected %s %s, got %s", fields[0], value, line)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.txt")
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{subDir, "--split-tokens", "0", "--include", "*.tmpl", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(result) != "files.tmpl\n" {
+		t.Errorf("Expected template output, got:\n%s", result)
+	}
+}
+
+func TestIntegration_ConfigShow(t *testing.T) {
+	userDir := isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatalf("Failed to create user config directory: %v", err)
+	}
+	userPath := filepath.Join(userDir, "config.yaml")
+	if err := os.WriteFile(userPath, []byte("tokenizer: o200k\nformat: xml\n"), 0644); err != nil {
+		t.Fatalf("Failed to create user config: %v", err)
+	}
+	// Parents are searched up to the repository root
+	if err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	repoPath := filepath.Join(tmpDir, ".treecat.toml")
+	if err := os.WriteFile(repoPath, []byte("format = \"markdown\"\nexclude = [\"*.log\", \"dist/**\"]\nmax-tokens = 1000\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	subDir := filepath.Join(tmpDir, "sub")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create sub: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"config", "show", subDir, "--max-tokens", "500"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	settings := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		fields := strings.Fields(line)
+		settings[fields[0]] = fields[1:]
+	}
+
+	expected := map[string][]string{
+		"format":     {"markdown", repoPath},
+		"exclude":    {"[*.log,", "dist/**]", repoPath},
+		"tokenizer":  {"o200k", userPath},
+		"max-tokens": {"500", "flag"},
+		"binary":     {"placeholder", "default"},
+		"output":     {`""`, "default"},
+	}
+	for name, fields := range expected {
+		if strings.Join(settings[name], " ") != strings.Join(fields, " ") {
+			t.Errorf("Expected %s %v, got %v", name, fields, settings[name])
+		}
+	}
+	if _, ok := settings["help"]; ok {
+		t.Error("Expected no help setting")
+	}
+}
+
+func TestIntegration_ConfigErrors(t *testing.T) {
+	isolateUserConfig(t)
+
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{"unknown", "colour: true\n", "unknown setting colour in "},
+		{"invalid value", "max-tokens: many\n", "invalid value for max-tokens in "},
+		{"list", "max-tokens: [1, 2]\n", "invalid value for max-tokens in "},
+		{"conflict", "format: markdown\ntemplate: files.tmpl\n", "--template cannot be used with --format"},
+		{"absolute output", "output: /tmp/clobbered.txt\n", "absolute paths are only allowed in the user config file"},
+		{"escaping output", "output: ../clobbered.txt\n", "path must be within the directory of the config file"},
+		{"escaping template", "template: sub/../../files.tmpl\n", "path must be within the directory of the config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, ".treecat.yaml"), []byte(tt.config), 0644); err != nil {
+				t.Fatalf("Failed to create config: %v", err)
+			}
+
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{tmpDir})
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected %q error, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
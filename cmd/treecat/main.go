@@ -1,17 +1,23 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
 
 	"github.com/onozaty/treecat/internal/encoding"
 	"github.com/onozaty/treecat/internal/filter"
 	"github.com/onozaty/treecat/internal/output"
 	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tokenizer"
 	"github.com/onozaty/treecat/internal/tree"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -19,6 +25,23 @@ var (
 	Commit  = "dev"
 )
 
+// defaultMaxMemory is the default of --max-memory.
+const defaultMaxMemory = 256 << 20
+
+// exitCodeUnreadable is the exit code when --keep-going skipped unreadable files.
+const exitCodeUnreadable = 3
+
+// exitError makes main exit with a specific code.
+// Its details have already been reported on stderr.
+type exitError struct {
+	code    int
+	message string
+}
+
+func (e *exitError) Error() string {
+	return e.message
+}
+
 func newRootCmd() *cobra.Command {
 	versionInfo := Version
 	if Commit != "dev" {
@@ -36,29 +59,94 @@ Perfect for providing codebase context to LLMs.`,
 		RunE:    run,
 	}
 
-	cmd.Flags().StringSliceP("exclude", "e", []string{}, "Exclude patterns (comma-separated glob patterns)")
-	cmd.Flags().StringSliceP("include", "i", []string{}, "Include patterns (comma-separated glob patterns)")
-	cmd.Flags().Bool("no-gitignore", false, "Ignore .gitignore file")
-	cmd.Flags().String("encoding-map", "", "Per-extension encoding map (e.g., txt:shift_jis,log:euc-jp)")
-	cmd.Flags().StringP("output", "o", "", "Output file (write to file instead of stdout)")
+	addRootFlags(cmd.Flags())
+
+	cmd.AddCommand(newUnpackCmd())
+	cmd.AddCommand(newApplyCmd())
+	cmd.AddCommand(newConfigCmd())
+	cmd.AddCommand(newTemplateCmd())
+	cmd.CompletionOptions.DisableDefaultCmd = true
 
 	return cmd
 }
 
+// addRootFlags defines the flags of the root command, which can also be set
+// in config files (see applyConfig).
+func addRootFlags(flags *pflag.FlagSet) {
+	flags.StringSliceP("exclude", "e", []string{}, "Exclude patterns (comma-separated glob patterns)")
+	flags.StringSliceP("include", "i", []string{}, "Include patterns (comma-separated glob patterns)")
+	flags.Bool("no-gitignore", false, "Ignore .gitignore and git exclude files")
+	flags.Bool("follow-symlinks", false, "Follow symbolic links to files and directories")
+	flags.Bool("no-external-symlinks", false, "Skip symbolic links pointing outside the target directory (with --follow-symlinks)")
+	flags.String("encoding-map", "", "Encoding rules by glob pattern or extension, first match wins (e.g., legacy/**/*.txt:shift_jis,log:euc-jp)")
+	flags.String("default-encoding", "", "Encoding of files matching no --encoding-map rule (default utf-8)")
+	flags.String("invalid-bytes", "replace", "How to handle byte sequences invalid in the encoding: replace, error, skip-file or escape")
+	flags.String("output-encoding", "utf-8", "Encoding of the output (e.g., shift_jis)")
+	flags.String("eol", "lf", "Line endings of the output: lf, crlf or preserve (leaves those of file contents as they are)")
+	flags.Bool("detect-encoding", false, "Detect the encoding of files not in --encoding-map")
+	flags.StringP("output", "o", "", "Output file (write to file instead of stdout)")
+	flags.String("format", "plain", "Output format: plain, markdown, xml, json or jsonl")
+	flags.String("template", "", "Output template file (Go text/template, overrides --format)")
+	flags.Bool("tokens", false, "Report per-file and total token counts to stderr")
+	flags.String("tokenizer", "cl100k", "Tokenizer for token counting: cl100k, o200k or estimate")
+	flags.Int("max-tokens", 0, "Maximum number of tokens in the output (truncates and drops files beyond the budget)")
+	flags.Int("max-file-lines", 0, "Maximum lines per file (keeps the first and last lines, truncating the middle)")
+	flags.Int64("max-file-bytes", 0, "Maximum bytes per file (keeps the first and last lines, truncating the middle)")
+	flags.Int64("split-size", 0, "Split the output into files of at most this many bytes (requires --output)")
+	flags.Int("split-tokens", 0, "Split the output into files of at most this many tokens (requires --output)")
+	flags.Bool("tree-stats", false, "Annotate the tree with file sizes, line counts and tokens")
+	flags.String("binary", "placeholder", "How to handle binary files: placeholder, skip or include")
+	flags.Bool("keep-going", false, "Replace unreadable files with a placeholder instead of failing (exits with code 3)")
+	flags.IntP("jobs", "j", 0, "Number of files read in parallel (0 for the number of CPUs)")
+	flags.Int64("max-memory", defaultMaxMemory, "Maximum bytes of file contents read ahead in parallel (0 for unlimited)")
+	flags.Bool("verbose", false, "Report the encoding of each file to stderr")
+}
+
 var rootCmd = newRootCmd()
 
 func run(cmd *cobra.Command, args []string) error {
+	// Get target directory (default to current directory)
+	targetDir := "."
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	// Set flags not given on the command line from config files
+	if _, err := applyConfig(cmd.Flags(), targetDir); err != nil {
+		return err
+	}
+
 	// Get flag values
 	excludePatterns, _ := cmd.Flags().GetStringSlice("exclude")
 	includePatterns, _ := cmd.Flags().GetStringSlice("include")
 	noGitignore, _ := cmd.Flags().GetBool("no-gitignore")
+	followSymlinks, _ := cmd.Flags().GetBool("follow-symlinks")
+	noExternalSymlinks, _ := cmd.Flags().GetBool("no-external-symlinks")
 	encodingMapStr, _ := cmd.Flags().GetString("encoding-map")
+	defaultEncoding, _ := cmd.Flags().GetString("default-encoding")
+	detectEncoding, _ := cmd.Flags().GetBool("detect-encoding")
+	invalidBytesStr, _ := cmd.Flags().GetString("invalid-bytes")
+	outputEncodingName, _ := cmd.Flags().GetString("output-encoding")
+	eolStr, _ := cmd.Flags().GetString("eol")
 	outputPath, _ := cmd.Flags().GetString("output")
+	binaryModeStr, _ := cmd.Flags().GetString("binary")
+	formatStr, _ := cmd.Flags().GetString("format")
+	templatePath, _ := cmd.Flags().GetString("template")
+	showTokens, _ := cmd.Flags().GetBool("tokens")
+	tokenizerName, _ := cmd.Flags().GetString("tokenizer")
+	maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+	treeStats, _ := cmd.Flags().GetBool("tree-stats")
+	maxFileLines, _ := cmd.Flags().GetInt("max-file-lines")
+	maxFileBytes, _ := cmd.Flags().GetInt64("max-file-bytes")
+	splitSize, _ := cmd.Flags().GetInt64("split-size")
+	splitTokens, _ := cmd.Flags().GetInt("split-tokens")
+	keepGoing, _ := cmd.Flags().GetBool("keep-going")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	maxMemory, _ := cmd.Flags().GetInt64("max-memory")
+	verbose, _ := cmd.Flags().GetBool("verbose")
 
-	// Get target directory (default to current directory)
-	targetDir := "."
-	if len(args) > 0 {
-		targetDir = args[0]
+	if noExternalSymlinks && !followSymlinks {
+		return fmt.Errorf("--no-external-symlinks requires --follow-symlinks")
 	}
 
 	// Convert to absolute path
@@ -93,21 +181,154 @@ func run(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create scanner: %w", err)
 	}
+	scan.FollowSymlinks = followSymlinks
+	scan.NoExternalSymlinks = noExternalSymlinks
+	scan.KeepGoing = keepGoing
 
 	// Scan directory
 	entries, err := scan.Scan()
 	if err != nil {
 		return fmt.Errorf("failed to scan directory: %w", err)
 	}
+	for _, warning := range scan.Warnings() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
 
 	// Build tree (pass original targetDir for display)
 	treeRoot := tree.Build(entries, targetDir)
 
-	// Parse encoding map
-	encodingMap, err := encoding.ParseEncodingMap(encodingMapStr)
+	// Parse encoding rules
+	if defaultEncoding != "" && detectEncoding {
+		return fmt.Errorf("--default-encoding cannot be used with --detect-encoding")
+	}
+	encodingRules, err := encoding.ParseRules(encodingMapStr, defaultEncoding)
 	if err != nil {
 		return fmt.Errorf("failed to parse encoding map: %w", err)
 	}
+	invalidBytes, err := encoding.ParseInvalidBytes(invalidBytesStr)
+	if err != nil {
+		return err
+	}
+
+	// Parse output encoding and line endings
+	outputEncoding, err := encoding.NewConverter(outputEncodingName)
+	if err != nil {
+		return fmt.Errorf("invalid output encoding: %w", err)
+	}
+	eol, err := encoding.ParseEOL(eolStr)
+	if err != nil {
+		return err
+	}
+
+	// Parse binary mode
+	binaryMode, err := output.ParseBinaryMode(binaryModeStr)
+	if err != nil {
+		return err
+	}
+
+	// Parse output format
+	format, err := output.ParseFormat(formatStr)
+	if err != nil {
+		return err
+	}
+
+	// Load output template
+	var tmpl *template.Template
+	if templatePath != "" {
+		if cmd.Flags().Changed("format") {
+			return fmt.Errorf("--template cannot be used with --format")
+		}
+		tmpl, err = output.LoadTemplate(templatePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate per-file limits
+	if maxFileLines < 0 {
+		return fmt.Errorf("invalid --max-file-lines: %d", maxFileLines)
+	}
+	if maxFileBytes < 0 {
+		return fmt.Errorf("invalid --max-file-bytes: %d", maxFileBytes)
+	}
+
+	// Validate split options
+	if splitSize < 0 {
+		return fmt.Errorf("invalid --split-size: %d", splitSize)
+	}
+	if splitTokens < 0 {
+		return fmt.Errorf("invalid --split-tokens: %d", splitTokens)
+	}
+	split := splitSize > 0 || splitTokens > 0
+	if splitSize > 0 && splitTokens > 0 {
+		return fmt.Errorf("--split-size cannot be used with --split-tokens")
+	}
+	if split && outputPath == "" {
+		return fmt.Errorf("--split-size and --split-tokens require --output")
+	}
+
+	// Validate parallel reading options
+	if jobs < 0 {
+		return fmt.Errorf("invalid --jobs: %d", jobs)
+	}
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	if maxMemory < 0 {
+		return fmt.Errorf("invalid --max-memory: %d", maxMemory)
+	}
+
+	// Create tokenizer (only when tokens are needed).
+	// Tree stats fall back to estimated tokens unless a tokenizer is specified.
+	var tok tokenizer.Tokenizer
+	if maxTokens < 0 {
+		return fmt.Errorf("invalid --max-tokens: %d", maxTokens)
+	}
+	if showTokens || maxTokens > 0 || splitTokens > 0 || (treeStats && cmd.Flags().Changed("tokenizer")) {
+		tok, err = tokenizer.New(tokenizerName)
+		if err != nil {
+			return err
+		}
+	}
+
+	options := output.Options{
+		EncodingRules:  encodingRules,
+		DetectEncoding: detectEncoding,
+		InvalidBytes:   invalidBytes,
+		OutputEncoding: outputEncoding,
+		EOL:            eol,
+		BinaryMode:     binaryMode,
+		Format:         format,
+		Template:       tmpl,
+		Tokenizer:      tok,
+		MaxTokens:      maxTokens,
+		TreeStats:      treeStats,
+		MaxFileLines:   maxFileLines,
+		MaxFileBytes:   maxFileBytes,
+		SplitSize:      splitSize,
+		SplitTokens:    splitTokens,
+		KeepGoing:      keepGoing,
+		Jobs:           jobs,
+		MaxMemory:      maxMemory,
+	}
+
+	// Write numbered part files instead of a single output
+	if split {
+		formatter := output.NewFormatterWithOptions(nil, options)
+		parts, err := formatter.FormatSplit(treeRoot, entries)
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		if err := writeParts(cmd.ErrOrStderr(), outputPath, parts); err != nil {
+			return err
+		}
+
+		if verbose {
+			writeEncodingReport(cmd.ErrOrStderr(), formatter, invalidBytes)
+		}
+		writeTokenReport(cmd.ErrOrStderr(), formatter, tok, showTokens)
+		return reportFailures(cmd, formatter.Failures())
+	}
 
 	// Determine writer (stdout or file)
 	var writer io.Writer = os.Stdout
@@ -124,16 +345,146 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create formatter and output
-	formatter := output.NewFormatterWithEncodingMap(writer, encodingMap)
+	formatter := output.NewFormatterWithOptions(writer, options)
 	if err := formatter.Format(treeRoot, entries); err != nil {
 		return fmt.Errorf("failed to format output: %w", err)
 	}
 
+	// Report encodings, tokens and files dropped by the token budget
+	if verbose {
+		writeEncodingReport(cmd.ErrOrStderr(), formatter, invalidBytes)
+	}
+	writeTokenReport(cmd.ErrOrStderr(), formatter, tok, showTokens)
+
+	return reportFailures(cmd, formatter.Failures())
+}
+
+// reportFailures lists the files and directories skipped by --keep-going on
+// stderr and returns an error exiting with exitCodeUnreadable, or nil if
+// everything could be read.
+func reportFailures(cmd *cobra.Command, failures []output.Failure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	w := cmd.ErrOrStderr()
+	fmt.Fprintf(w, "Could not read %d path(s):\n", len(failures))
+	for _, failure := range failures {
+		path := failure.RelPath
+		if failure.IsDir {
+			path += "/"
+		}
+		fmt.Fprintf(w, "  %s (%s)\n", path, scanner.ErrorReason(failure.Err))
+	}
+
+	// The output was written, so don't print the error or usage
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitError{
+		code:    exitCodeUnreadable,
+		message: fmt.Sprintf("could not read %d path(s)", len(failures)),
+	}
+}
+
+// writeParts writes each part to a numbered file derived from outputPath
+// (e.g., out.txt -> out.001.txt, out.002.txt, ...) and lists them on w.
+func writeParts(w io.Writer, outputPath string, parts [][]byte) error {
+	for i, part := range parts {
+		path := partPath(outputPath, i+1)
+		if err := os.WriteFile(path, part, 0644); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		fmt.Fprintf(w, "Wrote part %d of %d: %s\n", i+1, len(parts), path)
+	}
 	return nil
 }
 
+// partPath returns the path of a part file, inserting the zero-padded part
+// number before the extension.
+func partPath(outputPath string, part int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(outputPath, ext), part, ext)
+}
+
+// writeEncodingReport writes the encoding each file was decoded from,
+// marking the detected ones. Binary and unreadable files are shown with "-".
+func writeEncodingReport(w io.Writer, formatter *output.Formatter, invalidBytes encoding.InvalidBytes) {
+	handled := "replaced"
+	if invalidBytes == encoding.InvalidEscape {
+		handled = "escaped"
+	}
+
+	fmt.Fprintln(w, "Encodings:")
+	for _, stat := range formatter.Stats() {
+		name := stat.Encoding
+		if name == "" {
+			name = "-"
+		}
+
+		var notes []string
+		if stat.EncodingDetected {
+			notes = append(notes, "detected")
+		}
+		if stat.InvalidBytes > 0 {
+			notes = append(notes, fmt.Sprintf("%d invalid byte(s) %s", stat.InvalidBytes, handled))
+		}
+		if stat.Skipped {
+			notes = append(notes, "skipped")
+		}
+
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "  %-12s  %s (%s)\n", name, stat.RelPath, strings.Join(notes, ", "))
+		} else {
+			fmt.Fprintf(w, "  %-12s  %s\n", name, stat.RelPath)
+		}
+	}
+}
+
+// writeTokenReport writes per-file token counts (if showTokens is set) and
+// the files truncated or dropped to fit the token budget.
+func writeTokenReport(w io.Writer, formatter *output.Formatter, tok tokenizer.Tokenizer, showTokens bool) {
+	if tok == nil {
+		return
+	}
+
+	var dropped []string
+	if showTokens {
+		fmt.Fprintf(w, "Tokens (%s):\n", tok.Name())
+	}
+	for _, stat := range formatter.Stats() {
+		if stat.Dropped {
+			dropped = append(dropped, stat.RelPath)
+		}
+		if !showTokens {
+			continue
+		}
+		switch {
+		case stat.Dropped:
+			fmt.Fprintf(w, "%10s  %s (dropped)\n", "-", stat.RelPath)
+		case stat.Truncated:
+			fmt.Fprintf(w, "%10d  %s (truncated)\n", stat.Tokens, stat.RelPath)
+		default:
+			fmt.Fprintf(w, "%10d  %s\n", stat.Tokens, stat.RelPath)
+		}
+	}
+	if showTokens {
+		fmt.Fprintf(w, "%10d  total\n", formatter.TotalTokens())
+	}
+
+	if len(dropped) > 0 {
+		fmt.Fprintf(w, "Dropped %d file(s) to fit the token budget:\n", len(dropped))
+		for _, path := range dropped {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
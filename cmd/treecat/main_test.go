@@ -2,14 +2,34 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
 )
 
+// TestMain points the home and user config directories to an empty directory,
+// so that the config files of the user running the tests are not loaded.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "treecat-home")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create home directory: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 func TestIntegration_BasicDirectory(t *testing.T) {
 	// Create temporary test directory
 	tmpDir := t.TempDir()
@@ -220,6 +240,123 @@ package main
 	}
 }
 
+func TestIntegration_WithNestedGitignore(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create nested directory with its own .gitignore
+	subDir := filepath.Join(tmpDir, "service")
+	if err := os.MkdirAll(filepath.Join(subDir, "dist"), 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, ".gitignore"), []byte("dist/\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "dist", "app.js"), []byte("built"), 0644); err != nil {
+		t.Fatalf("Failed to create dist file: %v", err)
+	}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Run command (create new instance for test isolation)
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir})
+	err := cmd.Execute()
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	// Read captured output
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	expectedTree := `└── service/
+    ├── .gitignore
+    └── main.go
+
+=== service/.gitignore ===
+dist/
+
+=== service/main.go ===
+package main
+`
+
+	expected := tmpDir + "\n" + expectedTree
+
+	if output != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, output)
+	}
+}
+
+func TestIntegration_GitignoreFromRepositoryRoot(t *testing.T) {
+	repoDir := t.TempDir()
+
+	// Repository root with .gitignore; scan only a subdirectory
+	if err := os.MkdirAll(filepath.Join(repoDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".gitignore"), []byte("*.pb.go\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+	subDir := filepath.Join(repoDir, "internal")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "api.go"), []byte("package internal"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "api.pb.go"), []byte("generated"), 0644); err != nil {
+		t.Fatalf("Failed to create generated file: %v", err)
+	}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Run command (create new instance for test isolation)
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{subDir})
+	err := cmd.Execute()
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	// Read captured output
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	// Paths stay relative to the requested directory
+	expectedTree := `└── api.go
+
+=== api.go ===
+package internal
+`
+
+	expected := subDir + "\n" + expectedTree
+
+	if output != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, output)
+	}
+}
+
 func TestIntegration_WithNoGitignore(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -572,6 +709,65 @@ func TestIntegration_InvalidEncoding(t *testing.T) {
 	}
 }
 
+func TestIntegration_EncodingRules(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	text := "日本語のテキスト"
+	sjisBytes, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("Failed to encode to Shift_JIS: %v", err)
+	}
+	eucJPBytes, err := japanese.EUCJP.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("Failed to encode to EUC-JP: %v", err)
+	}
+
+	files := map[string][]byte{
+		filepath.Join(tmpDir, "legacy", "old.txt"): sjisBytes,
+		filepath.Join(tmpDir, "docs", "new.txt"):   []byte(text),
+		filepath.Join(tmpDir, "other.txt"):         eucJPBytes,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--encoding-map", "legacy/**/*.txt:shift_jis,docs/**/*.txt:utf-8", "--default-encoding", "euc-jp", "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if count := strings.Count(string(result), text); count != 3 {
+		t.Errorf("Expected 3 converted files, got %d:\n%s", count, result)
+	}
+}
+
+func TestIntegration_DefaultEncodingWithDetectEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--default-encoding", "shift_jis", "--detect-encoding"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected error for --default-encoding with --detect-encoding")
+	}
+	if !strings.Contains(err.Error(), "cannot be used with --detect-encoding") {
+		t.Errorf("Expected 'cannot be used with --detect-encoding' error, got: %v", err)
+	}
+}
+
 func TestIntegration_EncodingWithMultipleFiles(t *testing.T) {
 	// Create temporary test directory with subdirectory
 	tmpDir := t.TempDir()
@@ -674,6 +870,197 @@ func TestIntegration_NoEncodingFlag(t *testing.T) {
 	}
 }
 
+func TestIntegration_DetectEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	originalText := "// 日本語のコメントです\n"
+	sjisBytes, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(originalText))
+	if err != nil {
+		t.Fatalf("Failed to encode to Shift_JIS: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "japanese.txt"), sjisBytes, 0644); err != nil {
+		t.Fatalf("Failed to create Shift_JIS file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "english.txt"), []byte("Hello, World!\n"), 0644); err != nil {
+		t.Fatalf("Failed to create UTF-8 file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{tmpDir, "--detect-encoding", "--verbose", "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(result), "=== japanese.txt ===\n"+originalText) {
+		t.Errorf("Expected converted content in output, got:\n%s", result)
+	}
+
+	expected := "Encodings:\n" +
+		"  utf-8         english.txt (detected)\n" +
+		"  shift_jis     japanese.txt (detected)\n"
+	if stderr.String() != expected {
+		t.Errorf("Expected report:\n%s\nGot:\n%s", expected, stderr.String())
+	}
+}
+
+func TestIntegration_UTF16WithBOM(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// PowerShell scripts are often saved as UTF-16LE with a BOM
+	utf16Bytes, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("Write-Host \"こんにちは\"\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to encode to UTF-16: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "script.ps1"), utf16Bytes, 0644); err != nil {
+		t.Fatalf("Failed to create UTF-16 file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	// No encoding options are needed
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	expected := "=== script.ps1 ===\nWrite-Host \"こんにちは\"\n"
+	if !strings.Contains(string(result), expected) {
+		t.Errorf("Expected %q in output, got:\n%q", expected, result)
+	}
+}
+
+func TestIntegration_InvalidBytes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "latin1.txt"), []byte("caf\xe9 \xe0 la carte\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "valid.txt"), []byte("café\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		policy   string
+		expected string
+		report   string
+	}{
+		{"replace", "caf\uFFFD \uFFFD la carte\n", "  utf-8         latin1.txt (2 invalid byte(s) replaced)\n"},
+		{"escape", `caf\xE9 \xE0 la carte` + "\n", "  utf-8         latin1.txt (2 invalid byte(s) escaped)\n"},
+		{"skip-file", "[skipped file: invalid utf-8 byte sequence at offset 3]\n", "  utf-8         latin1.txt (skipped)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			outputFile := filepath.Join(t.TempDir(), "output.txt")
+
+			var stderr bytes.Buffer
+			cmd := newRootCmd()
+			cmd.SetErr(&stderr)
+			cmd.SetArgs([]string{tmpDir, "--invalid-bytes", tt.policy, "--verbose", "--output", outputFile})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+
+			result, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if !strings.Contains(string(result), "=== latin1.txt ===\n"+tt.expected) {
+				t.Errorf("Expected %q in output, got:\n%s", tt.expected, result)
+			}
+			if !strings.Contains(string(result), "=== valid.txt ===\ncafé\n") {
+				t.Errorf("Expected valid content in output, got:\n%s", result)
+			}
+
+			expected := "Encodings:\n" + tt.report + "  utf-8         valid.txt\n"
+			if stderr.String() != expected {
+				t.Errorf("Expected report:\n%s\nGot:\n%s", expected, stderr.String())
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{tmpDir, "--invalid-bytes", "error", "--output", filepath.Join(t.TempDir(), "output.txt")})
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "latin1.txt: invalid utf-8 byte sequence at offset 3") {
+			t.Errorf("Expected invalid byte error, got: %v", err)
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{tmpDir, "--invalid-bytes", "ignore"})
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "invalid --invalid-bytes policy: ignore") {
+			t.Errorf("Expected invalid policy error, got: %v", err)
+		}
+	})
+}
+func TestIntegration_OutputEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "readme.txt"), []byte("日本語\nテキスト\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.txt")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--output-encoding", "Shift_JIS", "--eol", "crlf", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(result)
+	if err != nil {
+		t.Fatalf("Failed to decode output: %v", err)
+	}
+
+	expected := tmpDir + "\r\n└── readme.txt\r\n\r\n=== readme.txt ===\r\n日本語\r\nテキスト\r\n\r\n"
+	if string(decoded) != expected {
+		t.Errorf("Expected %q, got %q", expected, decoded)
+	}
+}
+
+func TestIntegration_InvalidOutputEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--output-encoding", "unknown"}, "invalid output encoding: unsupported encoding: unknown"},
+		{[]string{"--eol", "cr"}, "invalid line ending: cr (expected lf, crlf or preserve)"},
+	}
+
+	for _, tt := range tests {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{tmpDir}, tt.args...))
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected %q error, got: %v", tt.expected, err)
+		}
+	}
+}
+
 func TestIntegration_OutputToFile(t *testing.T) {
 	// Create temporary test directory
 	tmpDir := t.TempDir()
@@ -766,3 +1153,521 @@ func TestIntegration_OutputToFileShortFlag(t *testing.T) {
 		t.Error("Output file does not contain expected content")
 	}
 }
+
+func TestIntegration_BinarySkip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create a text file and a binary file in a subdirectory
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "lib", "libfoo.so"), []byte("\x7fELF\x02\x01\x01\x00\x00\x00"), 0644); err != nil {
+		t.Fatalf("Failed to create binary file: %v", err)
+	}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Run command with --binary skip
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--binary", "skip"})
+	err := cmd.Execute()
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	// Read captured output
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	// Binary file and its now-empty directory are removed from the tree
+	expectedTree := `└── main.go
+
+=== main.go ===
+package main
+`
+
+	expected := tmpDir + "\n" + expectedTree
+
+	if output != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, output)
+	}
+}
+
+func TestIntegration_InvalidBinaryMode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--binary", "invalid"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected error for invalid binary mode")
+	}
+	if !strings.Contains(err.Error(), "invalid binary mode") {
+		t.Errorf("Expected 'invalid binary mode' error, got: %v", err)
+	}
+}
+
+func TestIntegration_MarkdownFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Run command with --format markdown
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--format", "markdown"})
+	err := cmd.Execute()
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	// Read captured output
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	expected := "```\n" + tmpDir + "\n" +
+		"└── main.go\n" +
+		"```\n" +
+		"\n" +
+		"## main.go\n" +
+		"\n" +
+		"```go\n" +
+		"package main\n" +
+		"```\n"
+
+	if output != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, output)
+	}
+}
+
+func TestIntegration_Template(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+
+	templatePath := filepath.Join(tmpDir, "custom.tmpl")
+	templateText := "{{range .Files}}--- {{.RelPath}} ({{.Language}}) ---\n{{.Content}}{{end}}"
+	if err := os.WriteFile(templatePath, []byte(templateText), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{srcDir, "--template", templatePath, "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	outputContent, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expected := "--- main.go (go) ---\npackage main\n"
+	if string(outputContent) != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, string(outputContent))
+	}
+}
+
+func TestIntegration_TemplateWithFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--template", "custom.tmpl", "--format", "json"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected error when --template is used with --format")
+	}
+}
+
+func TestIntegration_TokenReport(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("12345678\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte(strings.Repeat("x", 400)+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create b.txt: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	// Run command with token report and a budget that can't fit b.txt (a single long line)
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{tmpDir, "--tokens", "--tokenizer", "estimate", "--max-tokens", "40", "--include", "*.txt", "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	report := stderr.String()
+	expectedLines := []string{
+		"Tokens (estimate):",
+		"a.txt",
+		"-  b.txt (dropped)",
+		"total",
+		"Dropped 1 file(s) to fit the token budget:\n  b.txt\n",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected %q in report, got:\n%s", line, report)
+		}
+	}
+}
+
+func TestIntegration_InvalidTokenizer(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--tokens", "--tokenizer", "unknown"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected error for unsupported tokenizer")
+	}
+	if !strings.Contains(err.Error(), "unsupported tokenizer") {
+		t.Errorf("Expected 'unsupported tokenizer' error, got: %v", err)
+	}
+}
+
+func TestIntegration_TreeStats(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create src: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "src", "a.txt"), []byte("line1\nline2\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "src", "b.txt"), []byte("12345678\n"), 0644); err != nil {
+		t.Fatalf("Failed to create b.txt: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	// Run command with tree stats
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--tree-stats", "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expectedLines := []string{
+		"└── src/ (2 files, 3 lines, 21 B, ~6 tok)\n",
+		"    ├── a.txt (2 lines, 12 B, ~3 tok)\n",
+		"    └── b.txt (1 line, 9 B, ~3 tok)\n",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("Expected %q in output, got:\n%s", line, string(content))
+		}
+	}
+}
+
+func TestIntegration_MaxFileLines(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("log %d", i))
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "app.log"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create app.log: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	// Run command keeping 4 lines per file
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--max-file-lines", "4", "--exclude", "output.txt", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expected := "└── app.log [truncated]\n\n" +
+		"=== app.log ===\n" +
+		"log 1\nlog 2\n… [truncated 96 lines] …\nlog 99\nlog 100\n\n"
+	if !strings.HasSuffix(string(content), expected) {
+		t.Errorf("Expected output to end with:\n%s\nGot:\n%s", expected, string(content))
+	}
+}
+
+func TestIntegration_SplitSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create src: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(strings.Repeat("x", 100)+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	outputFile := filepath.Join(tmpDir, "out.txt")
+
+	// Use a relative target so that the size of the tree doesn't depend on the temp directory
+	t.Chdir(tmpDir)
+
+	// Run command with a split size fitting two files per part
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"src", "--split-size", "350", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	// The output path itself is not written
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created", outputFile)
+	}
+
+	expectedParts := map[string][]string{
+		"out.001.txt": {"--- part 1 of 2 ---\n", "=== a.txt ===\n", "=== b.txt ===\n"},
+		"out.002.txt": {"--- part 2 of 2 ---\n", "=== c.txt ===\n"},
+	}
+	for name, expectedLines := range expectedParts {
+		content, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if len(content) > 350 {
+			t.Errorf("%s exceeds the split size: %d bytes", name, len(content))
+		}
+		for _, line := range expectedLines {
+			if !strings.Contains(string(content), line) {
+				t.Errorf("Expected %q in %s, got:\n%s", line, name, string(content))
+			}
+		}
+	}
+
+	if !strings.Contains(stderr.String(), "Wrote part 2 of 2: "+filepath.Join(tmpDir, "out.002.txt")) {
+		t.Errorf("Expected parts to be reported, got:\n%s", stderr.String())
+	}
+}
+
+func TestIntegration_SplitWithoutOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{tmpDir, "--split-size", "1000"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("Expected error for --split-size without --output")
+	}
+	if !strings.Contains(err.Error(), "require --output") {
+		t.Errorf("Expected 'require --output' error, got: %v", err)
+	}
+}
+
+func TestIntegration_FollowSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, dir := range []string{"shared/proto", "ws"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "shared", "proto", "a.proto"), []byte("syntax = \"proto3\";\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.proto: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "shared", "proto"), filepath.Join(tmpDir, "ws", "proto")); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+	if err := os.Symlink(".", filepath.Join(tmpDir, "ws", "self")); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{filepath.Join(tmpDir, "ws"), "--follow-symlinks", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	for _, expected := range []string{
+		"└── proto/ -> ../shared/proto\n    └── a.proto\n",
+		"=== proto/a.proto ===\nsyntax = \"proto3\";\n",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected %q in output, got:\n%s", expected, string(content))
+		}
+	}
+	if stderr.String() != "Warning: skipped symbolic link to a directory already scanned: self -> .\n" {
+		t.Errorf("Unexpected warnings: %q", stderr.String())
+	}
+
+	// External links are skipped with --no-external-symlinks
+	stderr.Reset()
+	cmd = newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{filepath.Join(tmpDir, "ws"), "--follow-symlinks", "--no-external-symlinks", "--output", outputFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	content, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if strings.Contains(string(content), "a.proto") {
+		t.Errorf("Expected external link to be skipped, got:\n%s", string(content))
+	}
+	if !strings.Contains(stderr.String(), "Warning: skipped symbolic link pointing outside the root: proto -> ../shared/proto\n") {
+		t.Errorf("Expected warning for external link, got %q", stderr.String())
+	}
+}
+
+func TestIntegration_KeepGoing(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create main.go: %v", err)
+	}
+	// A broken link is listed by the scan but can't be read
+	if err := os.Symlink("missing.txt", filepath.Join(tmpDir, "broken.txt")); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.txt")
+
+	// Fails on the unreadable file by default
+	cmd := newRootCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{tmpDir, "--output", outputFile})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected error for unreadable file")
+	}
+
+	var stderr bytes.Buffer
+	cmd = newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{tmpDir, "--keep-going", "--output", outputFile})
+	err := cmd.Execute()
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != exitCodeUnreadable {
+		t.Fatalf("Expected exit code %d, got %v", exitCodeUnreadable, err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	for _, expected := range []string{
+		"├── broken.txt [error: no such file or directory]\n",
+		"=== broken.txt ===\n[unreadable file: no such file or directory]\n",
+		"=== main.go ===\npackage main\n",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected %q in output, got:\n%s", expected, string(content))
+		}
+	}
+
+	expectedStderr := "Could not read 1 path(s):\n  broken.txt (no such file or directory)\n"
+	if stderr.String() != expectedStderr {
+		t.Errorf("Expected stderr %q, got %q", expectedStderr, stderr.String())
+	}
+}
+
+func TestIntegration_Jobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+
+	for i := range 30 {
+		dir := filepath.Join(srcDir, fmt.Sprintf("pkg%d", i%4))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		content := strings.Repeat(fmt.Sprintf("line of file %d\n", i), i*50+1)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	outputs := make(map[string]string)
+	for _, args := range [][]string{
+		{"--jobs", "1"},
+		{"--jobs", "8", "--max-memory", "1024"},
+		{"-j", "3", "--tree-stats"},
+		{"-j", "1", "--tree-stats"},
+	} {
+		outputFile := filepath.Join(tmpDir, "output.txt")
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{srcDir, "--output", outputFile}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed with %v: %v", args, err)
+		}
+		content, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+		outputs[strings.Join(args, " ")] = string(content)
+	}
+
+	if outputs["--jobs 8 --max-memory 1024"] != outputs["--jobs 1"] {
+		t.Error("Expected the same output with parallel reading")
+	}
+	if outputs["-j 3 --tree-stats"] != outputs["-j 1 --tree-stats"] {
+		t.Error("Expected the same tree stats with parallel reading")
+	}
+
+	cmd := newRootCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{srcDir, "--jobs", "-1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid --jobs") {
+		t.Errorf("Expected invalid --jobs error, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+
+	"github.com/onozaty/treecat/internal/output"
+	"github.com/spf13/cobra"
+)
+
+func newTemplateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Work with output templates",
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the built-in template of the plain format",
+		Long: `show prints the built-in template producing the plain format, to be used as a
+starting point for --template:
+
+  treecat template show > my-format.tmpl`,
+		Args: cobra.NoArgs,
+		RunE: runTemplateShow,
+	}
+	cmd.AddCommand(showCmd)
+
+	return cmd
+}
+
+func runTemplateShow(cmd *cobra.Command, args []string) error {
+	_, err := io.WriteString(cmd.OutOrStdout(), output.DefaultTemplate)
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntegration_TemplateShow(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create main.go: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"template", "show"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	templatePath := filepath.Join(tmpDir, "plain.tmpl")
+	if err := os.WriteFile(templatePath, stdout.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	// The printed template produces the plain format
+	format := func(args ...string) string {
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{srcDir, "--output", outputFile}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		result, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+		return string(result)
+	}
+
+	plain := format()
+	templated := format("--template", templatePath)
+	if templated != plain {
+		t.Errorf("Expected plain output:\n%s\nGot:\n%s", plain, templated)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/onozaty/treecat/internal/unpack"
+	"github.com/spf13/cobra"
+)
+
+func newUnpackCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unpack <bundle>...",
+		Short: "Recreate files from treecat output",
+		Long: `unpack parses treecat output in the plain, markdown or xml format and
+recreates each file under the destination directory. Use "-" to read from stdin.
+The parts of a split output can be given together, in order.
+Paths escaping the destination (absolute paths, "..", symbolic links) are refused.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runUnpack,
+	}
+
+	cmd.Flags().StringP("dir", "d", ".", "Destination directory")
+	cmd.Flags().String("format", "auto", "Bundle format: auto, plain, markdown or xml")
+	cmd.Flags().Bool("dry-run", false, "Show what would be written without writing files")
+	cmd.Flags().String("overwrite", "never", "Existing files: never (fail), skip or always (--overwrite alone means always)")
+	cmd.Flags().Lookup("overwrite").NoOptDefVal = "always"
+
+	return cmd
+}
+
+func runUnpack(cmd *cobra.Command, args []string) error {
+	destDir, _ := cmd.Flags().GetString("dir")
+	formatStr, _ := cmd.Flags().GetString("format")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	policyStr, _ := cmd.Flags().GetString("overwrite")
+
+	format, err := unpack.ParseFormat(formatStr)
+	if err != nil {
+		return err
+	}
+	policy, err := unpack.ParsePolicy(policyStr)
+	if err != nil {
+		return err
+	}
+
+	// Read bundles
+	var bundles [][]byte
+	for _, path := range args {
+		var data []byte
+		if path == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		bundles = append(bundles, data)
+	}
+
+	files, err := unpack.Parse(format, bundles...)
+	if err != nil {
+		return err
+	}
+
+	// Check every destination before writing anything
+	actions, err := unpack.Plan(files, destDir, policy)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	for _, action := range actions {
+		if action.Reason != "" {
+			fmt.Fprintf(w, "%-9s  %s (%s)\n", action.Op, action.File.Path, action.Reason)
+		} else {
+			fmt.Fprintf(w, "%-9s  %s\n", action.Op, action.File.Path)
+		}
+	}
+
+	if dryRun {
+		fmt.Fprintln(w, "Dry run: no files were written")
+		return nil
+	}
+
+	return unpack.Apply(actions)
+}
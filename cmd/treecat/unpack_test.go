@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIntegration_UnpackRoundTrip(t *testing.T) {
+	for _, format := range []string{"plain", "markdown", "xml"} {
+		t.Run(format, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			if err := os.MkdirAll(filepath.Join(srcDir, "pkg"), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			files := map[string]string{
+				"main.go":     "package main\n\nfunc main() {}\n",
+				"pkg/util.go": "package pkg\n\n// <b>&</b>\n",
+			}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(srcDir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to create %s: %v", name, err)
+				}
+			}
+
+			bundle := filepath.Join(tmpDir, "bundle.txt")
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{srcDir, "--format", format, "--output", bundle})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Command failed: %v", err)
+			}
+
+			destDir := filepath.Join(tmpDir, "dest")
+			var stdout bytes.Buffer
+			cmd = newRootCmd()
+			cmd.SetOut(&stdout)
+			cmd.SetArgs([]string{"unpack", bundle, "-d", destDir})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Unpack failed: %v", err)
+			}
+
+			for name, expected := range files {
+				content, err := os.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", name, err)
+				}
+				if string(content) != expected {
+					t.Errorf("%s: expected %q, got %q", name, expected, string(content))
+				}
+			}
+			if !strings.Contains(stdout.String(), "create     pkg/util.go\n") {
+				t.Errorf("Expected actions to be listed, got:\n%s", stdout.String())
+			}
+		})
+	}
+}
+
+func TestIntegration_UnpackDryRunAndOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundle := filepath.Join(tmpDir, "bundle.txt")
+	if err := os.WriteFile(bundle, []byte("=== a.txt ===\nnew\n\n=== b.txt ===\nb\n\n"), 0644); err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	destDir := filepath.Join(tmpDir, "dest")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		t.Fatalf("Failed to create dest: %v", err)
+	}
+	aPath := filepath.Join(destDir, "a.txt")
+	if err := os.WriteFile(aPath, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+
+	// Existing files are refused by default
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"unpack", bundle, "-d", destDir})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected 'already exists' error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "b.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written when unpack fails")
+	}
+
+	// Dry run lists the actions without writing
+	var stdout bytes.Buffer
+	cmd = newRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"unpack", bundle, "-d", destDir, "--overwrite", "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	expected := "overwrite  a.txt\ncreate     b.txt\nDry run: no files were written\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "b.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written in dry run")
+	}
+
+	// Skip keeps existing files
+	cmd = newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"unpack", bundle, "-d", destDir, "--overwrite=skip"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	content, _ := os.ReadFile(aPath)
+	if string(content) != "old\n" {
+		t.Errorf("Expected a.txt to be kept, got %q", string(content))
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "b.txt")); err != nil {
+		t.Errorf("Expected b.txt to be created: %v", err)
+	}
+}
+
+func TestIntegration_UnpackRejectsEscapingPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundle := filepath.Join(tmpDir, "bundle.txt")
+	if err := os.WriteFile(bundle, []byte("=== ok.txt ===\nok\n\n=== ../evil.txt ===\nevil\n\n"), 0644); err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	destDir := filepath.Join(tmpDir, "dest")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"unpack", bundle, "-d", destDir})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "escaping the destination") {
+		t.Fatalf("Expected path escape error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("Expected evil.txt not to be written")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "ok.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written when a path is refused")
+	}
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/onozaty/treecat/internal/filter"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names of config files discovered in the target directory
+// and its parents.
+var FileNames = []string{".treecat.yaml", ".treecat.toml"}
+
+// userFileNames are the names of the user-level config file in the treecat
+// directory of the user config directory (e.g., ~/.config/treecat/config.yaml).
+var userFileNames = []string{"config.yaml", "config.toml"}
+
+// Setting is a value of a config file, with the name of the CLI flag it sets.
+type Setting struct {
+	Name   string
+	Values []string // List elements, or a single value (empty for null)
+	List   bool     // Whether the value is a list
+	Source string   // Path of the config file
+	User   bool     // Whether the config file is the user-level one
+}
+
+// File is a parsed config file.
+type File struct {
+	Path     string
+	Settings []Setting // Sorted by name
+}
+
+// Load parses a YAML (.yaml, .yml) or TOML (.toml) config file.
+// The file is a flat table of CLI flag names (without "--") to values:
+// strings, numbers, booleans or lists of them.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	switch filepath.Ext(path) {
+	case ".toml":
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	file := &File{Path: path}
+	for name, value := range values {
+		setting, err := newSetting(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s in %s: %w", name, path, err)
+		}
+		setting.Source = path
+		file.Settings = append(file.Settings, setting)
+	}
+	sort.Slice(file.Settings, func(i, j int) bool { return file.Settings[i].Name < file.Settings[j].Name })
+
+	return file, nil
+}
+
+// newSetting converts a decoded value to the strings accepted by flags.
+func newSetting(name string, value any) (Setting, error) {
+	setting := Setting{Name: name}
+	switch v := value.(type) {
+	case nil:
+		setting.Values = []string{}
+	case []any:
+		setting.List = true
+		setting.Values = []string{}
+		for _, element := range v {
+			s, err := scalarString(element)
+			if err != nil {
+				return Setting{}, err
+			}
+			setting.Values = append(setting.Values, s)
+		}
+	default:
+		s, err := scalarString(v)
+		if err != nil {
+			return Setting{}, err
+		}
+		setting.Values = []string{s}
+	}
+	return setting, nil
+}
+
+// scalarString formats a string, number or boolean as a flag value.
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("expected a string, number, boolean or list, got %T", value)
+}
+
+// Discover returns the config files applying to dir, from the lowest to the
+// highest precedence: the user-level config file, then the files of dir's
+// parents from the top down to dir itself. A directory may have only one of
+// .treecat.yaml and .treecat.toml.
+//
+// Parents are searched up to the root of the git worktree containing dir, or
+// up to the home directory if dir is outside any worktree. If dir is in
+// neither, only dir itself is searched, so that config files in shared
+// directories (e.g., /tmp) don't apply.
+func Discover(dir string) ([]*File, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	if userDir, err := os.UserConfigDir(); err == nil {
+		path, err := findFile(filepath.Join(userDir, "treecat"), userFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			file, err := Load(path)
+			if err != nil {
+				return nil, err
+			}
+			for i := range file.Settings {
+				file.Settings[i].User = true
+			}
+			files = append(files, file)
+		}
+	}
+
+	top := absDir
+	if root, _ := filter.FindRepositoryRoot(absDir); root != "" {
+		top = root
+	} else if home, err := os.UserHomeDir(); err == nil && isWithin(absDir, home) {
+		top = home
+	}
+
+	var dirPaths []string
+	for current := absDir; ; current = filepath.Dir(current) {
+		path, err := findFile(current, FileNames)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			dirPaths = append(dirPaths, path)
+		}
+		if current == top || filepath.Dir(current) == current {
+			break
+		}
+	}
+	for i := len(dirPaths) - 1; i >= 0; i-- {
+		file, err := Load(dirPaths[i])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// findFile returns the path of the config file with one of the names in dir,
+// or an empty string if there is none.
+func findFile(dir string, names []string) (string, error) {
+	found := ""
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("multiple config files in %s: %s and %s", dir, filepath.Base(found), name)
+		}
+		found = path
+	}
+	return found, nil
+}
+
+// Merge merges settings of files given from the lowest to the highest
+// precedence. A setting replaces the one of the same name in lower files.
+func Merge(files []*File) map[string]Setting {
+	merged := map[string]Setting{}
+	for _, file := range files {
+		for _, setting := range file.Settings {
+			merged[setting.Name] = setting
+		}
+	}
+	return merged
+}
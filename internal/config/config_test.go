@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// isolateUserConfig points the user config directory to an empty directory
+// so that the config of the user running the tests is not loaded.
+func isolateUserConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	userDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatalf("UserConfigDir failed: %v", err)
+	}
+	return filepath.Join(userDir, "treecat")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	yamlPath := filepath.Join(tmpDir, ".treecat.yaml")
+	writeFile(t, yamlPath, `exclude:
+  - "*.log"
+  - dist/**
+format: markdown
+max-tokens: 100000
+verbose: true
+include:
+`)
+	tomlPath := filepath.Join(tmpDir, ".treecat.toml")
+	writeFile(t, tomlPath, `exclude = ["*.log", "dist/**"]
+format = "markdown"
+max-tokens = 100000
+verbose = true
+include = []
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			file, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			expected := []Setting{
+				{Name: "exclude", Values: []string{"*.log", "dist/**"}, List: true, Source: path},
+				{Name: "format", Values: []string{"markdown"}, Source: path},
+				{Name: "include", Values: []string{}, List: path == tomlPath, Source: path},
+				{Name: "max-tokens", Values: []string{"100000"}, Source: path},
+				{Name: "verbose", Values: []string{"true"}, Source: path},
+			}
+			if !reflect.DeepEqual(file.Settings, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, file.Settings)
+			}
+		})
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{".treecat.yaml", "format: [markdown\n", "failed to parse"},
+		{".treecat.toml", "format = \n", "failed to parse"},
+		{".treecat.yaml", "exclude:\n  pattern: dist\n", "invalid value for exclude"},
+		{".treecat.toml", "exclude = [[\"dist\"]]\n", "invalid value for exclude"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.content, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tc.name)
+			writeFile(t, path, tc.content)
+
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected %q error, got: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	userDir := isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	userPath := filepath.Join(userDir, "config.toml")
+	writeFile(t, userPath, "format = \"xml\"\ntokenizer = \"o200k\"\n")
+	// Outside the repository, so not applied
+	writeFile(t, filepath.Join(tmpDir, ".treecat.yaml"), "output: out.txt\n")
+	if err := os.MkdirAll(filepath.Join(tmpDir, "repo", ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	repoPath := filepath.Join(tmpDir, "repo", ".treecat.yaml")
+	writeFile(t, repoPath, "format: markdown\nverbose: true\n")
+	subPath := filepath.Join(tmpDir, "repo", "sub", ".treecat.toml")
+	writeFile(t, subPath, "verbose = false\n")
+
+	files, err := Discover(filepath.Join(tmpDir, "repo", "sub"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	// From the lowest to the highest precedence
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	expected := []string{userPath, repoPath, subPath}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %v, got %v", expected, paths)
+	}
+
+	merged := Merge(files)
+	expectedSettings := map[string]Setting{
+		"format":    {Name: "format", Values: []string{"markdown"}, Source: repoPath},
+		"tokenizer": {Name: "tokenizer", Values: []string{"o200k"}, Source: userPath, User: true},
+		"verbose":   {Name: "verbose", Values: []string{"false"}, Source: subPath},
+	}
+	if !reflect.DeepEqual(merged, expectedSettings) {
+		t.Errorf("Expected %+v, got %+v", expectedSettings, merged)
+	}
+}
+
+func TestDiscover_Home(t *testing.T) {
+	isolateUserConfig(t)
+	home := os.Getenv("HOME")
+
+	homePath := filepath.Join(home, ".treecat.yaml")
+	writeFile(t, homePath, "format: markdown\n")
+	subPath := filepath.Join(home, "work", "sub", ".treecat.toml")
+	writeFile(t, subPath, "verbose = true\n")
+
+	files, err := Discover(filepath.Join(home, "work", "sub"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	expected := []string{homePath, subPath}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+}
+
+func TestDiscover_OutsideHome(t *testing.T) {
+	isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	// Only the target directory itself is searched
+	writeFile(t, filepath.Join(tmpDir, ".treecat.yaml"), "output: clobbered.txt\n")
+	subPath := filepath.Join(tmpDir, "sub", ".treecat.yaml")
+	writeFile(t, subPath, "format: markdown\n")
+
+	files, err := Discover(filepath.Join(tmpDir, "sub"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != subPath {
+		t.Errorf("Expected only %s, got %d files", subPath, len(files))
+	}
+}
+
+func TestDiscover_NoFiles(t *testing.T) {
+	isolateUserConfig(t)
+
+	files, err := Discover(t.TempDir())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no config files, got %d", len(files))
+	}
+}
+
+func TestDiscover_MultipleFiles(t *testing.T) {
+	isolateUserConfig(t)
+	tmpDir := t.TempDir()
+
+	writeFile(t, filepath.Join(tmpDir, ".treecat.yaml"), "format: markdown\n")
+	writeFile(t, filepath.Join(tmpDir, ".treecat.toml"), "format = \"xml\"\n")
+
+	_, err := Discover(tmpDir)
+	if err == nil || !strings.Contains(err.Error(), "multiple config files in "+tmpDir+": .treecat.yaml and .treecat.toml") {
+		t.Errorf("Expected multiple config files error, got: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// detectCandidates are the legacy encodings tried by Detect, in the order
+// preferred when they score the same.
+var detectCandidates = []string{"shift_jis", "euc-jp", "gbk", "big5", "euc-kr", "windows-1252"}
+
+// commonHanzi are frequent Chinese characters in simplified and traditional
+// forms. Decoding with the wrong Chinese encoding rarely produces them.
+const commonHanzi = "的一是不了在人有我他这個个们們中来來上大为為和国國地到以说說时時要就出会會可也你对對生能而子那得于於着著下自之年过過发發后後作里裡用道行所然家种種事成方多经經么麼去法学學如都同现現当當没沒动動面起看定天分还還进進好小部其些主样樣理心"
+
+// invalidPenalty is subtracted from the score of a candidate for each
+// sequence that is invalid in that encoding.
+const invalidPenalty = 5
+
+// Detect guesses the encoding of content from its leading bytes and returns
+// a name accepted by NewConverter:
+//
+//   - a byte order mark identifies UTF-8, UTF-16 and UTF-32
+//   - ISO-2022-JP is identified by its escape sequences
+//   - UTF-16 without a BOM is identified by the NUL bytes of ASCII characters
+//   - content valid as UTF-8 (including ASCII) is UTF-8
+//   - otherwise the content is decoded with each of Shift_JIS, EUC-JP, GBK,
+//     Big5, EUC-KR and Windows-1252, and the encoding producing the most
+//     plausible text for its language (kana for Japanese, hangul for Korean,
+//     common hanzi for Chinese, accented letters for Western languages) with
+//     the fewest invalid sequences is chosen
+//
+// content may be truncated in the middle of a character.
+func Detect(content []byte) string {
+	if name := DetectBOM(content); name != "" {
+		return name
+	}
+	if isISO2022JP(content) {
+		return "iso-2022-jp"
+	}
+	if name := detectUTF16(content); name != "" {
+		return name
+	}
+	if validUTF8(content) {
+		return "utf-8"
+	}
+
+	best, bestScore := "", 0.0
+	for _, name := range detectCandidates {
+		score := scoreEncoding(content, name)
+		if best == "" || score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	return best
+}
+
+// DetectBOM returns the encoding identified by a byte order mark at the start
+// of content (utf-8, utf-16le, utf-16be, utf-32le or utf-32be), or an empty
+// string if there is none.
+func DetectBOM(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE, 0x00, 0x00}):
+		return "utf-32le"
+	case bytes.HasPrefix(content, []byte{0x00, 0x00, 0xFE, 0xFF}):
+		return "utf-32be"
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+	return ""
+}
+
+// validUTF8 reports whether content is valid UTF-8, ignoring an incomplete
+// sequence at the end.
+func validUTF8(content []byte) bool {
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r == utf8.RuneError && size <= 1 {
+			return !utf8.FullRune(content) && len(content) < utf8.UTFMax
+		}
+		content = content[size:]
+	}
+	return true
+}
+
+// isISO2022JP reports whether content is 7-bit and switches to JIS X 0208
+// with an ISO-2022-JP escape sequence.
+func isISO2022JP(content []byte) bool {
+	for _, b := range content {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return bytes.Contains(content, []byte("\x1b$B")) || bytes.Contains(content, []byte("\x1b$@"))
+}
+
+// detectUTF16 identifies UTF-16 without a BOM: most characters of source
+// code and text are ASCII, so one byte of each 16-bit unit is NUL.
+func detectUTF16(content []byte) string {
+	if len(content) < 4 {
+		return ""
+	}
+
+	var zeros [2]int
+	for i, b := range content {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+
+	units := len(content) / 2
+	switch {
+	case zeros[1] > units*2/5 && zeros[0] < units/20:
+		return "utf-16le"
+	case zeros[0] > units*2/5 && zeros[1] < units/20:
+		return "utf-16be"
+	}
+	return ""
+}
+
+// scoreEncoding decodes content with the encoding and scores how plausible
+// the result is as text of the languages using it.
+func scoreEncoding(content []byte, name string) float64 {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return 0
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return 0
+	}
+	text := string(decoded)
+
+	// An incomplete sequence at the end of a truncated sample is not penalized
+	if strings.HasSuffix(text, string(utf8.RuneError)) {
+		text = strings.TrimSuffix(text, string(utf8.RuneError))
+	}
+
+	score := 0.0
+	prev := rune(0)
+	for _, r := range text {
+		if r >= utf8.RuneSelf {
+			weight := runeWeight(name, r)
+			if prev < utf8.RuneSelf && weight > 0 {
+				weight = isolatedWeight(name, weight)
+			}
+			score += weight
+		}
+		prev = r
+	}
+	return score
+}
+
+// isolatedWeight adjusts the weight of a character following an ASCII one.
+// Accented letters are mostly surrounded by ASCII letters, while Western
+// text decoded as a CJK encoding gives characters isolated between ASCII
+// ones (the trail byte of CJK encodings can be ASCII), and CJK text decoded
+// as Windows-1252 gives long runs of Latin-1 letters.
+func isolatedWeight(name string, weight float64) float64 {
+	if name == "windows-1252" {
+		return weight * 2
+	}
+	return weight / 2
+}
+
+// runeWeight scores a decoded non-ASCII character for the language of the
+// encoding. Weights are roughly per byte, since CJK characters take two bytes
+// and Western ones take one.
+func runeWeight(name string, r rune) float64 {
+	switch {
+	case r == utf8.RuneError:
+		return -invalidPenalty
+	case r >= 0x80 && r < 0xA0:
+		// C1 control characters are not used in text
+		return -1
+	}
+
+	switch name {
+	case "shift_jis", "euc-jp":
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana) && r < 0xFF00:
+			return 3
+		case r >= 0xFF61 && r <= 0xFF9F:
+			// Half-width katakana are rare, but many other encodings decode to them
+			return -1
+		case unicode.Is(unicode.Han, r), isCJKPunctuation(r):
+			return 2
+		}
+	case "gbk", "big5":
+		switch {
+		case strings.ContainsRune(commonHanzi, r):
+			return 4
+		case unicode.Is(unicode.Han, r), isCJKPunctuation(r):
+			return 2
+		}
+	case "euc-kr":
+		switch {
+		case r >= 0xAC00 && r <= 0xD7A3:
+			return 3
+		case r >= 0x3130 && r <= 0x318F:
+			// Compatibility jamo are rare in text
+			return -1
+		case isCJKPunctuation(r):
+			return 2
+		case unicode.Is(unicode.Han, r):
+			return 1
+		}
+	case "windows-1252":
+		if unicode.IsLetter(r) && r <= 0xFF {
+			return 0.5
+		}
+	}
+	return 0
+}
+
+// isCJKPunctuation reports whether r is a CJK symbol or a full-width form.
+func isCJKPunctuation(r rune) bool {
+	return (r >= 0x3000 && r <= 0x303F) || (r >= 0xFF01 && r <= 0xFF5E)
+}
@@ -0,0 +1,209 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+func TestDetect_BOM(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"UTF-8", []byte("\xEF\xBB\xBFtext"), "utf-8"},
+		{"UTF-16LE", []byte("\xFF\xFEt\x00"), "utf-16le"},
+		{"UTF-16BE", []byte("\xFE\xFF\x00t"), "utf-16be"},
+		{"UTF-32LE", []byte("\xFF\xFE\x00\x00t\x00\x00\x00"), "utf-32le"},
+		{"UTF-32BE", []byte("\x00\x00\xFE\xFF\x00\x00\x00t"), "utf-32be"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := Detect(tc.input); result != tc.expected {
+				t.Errorf("Detect() = %q, want %q", result, tc.expected)
+			}
+			if result := DetectBOM(tc.input); result != tc.expected {
+				t.Errorf("DetectBOM() = %q, want %q", result, tc.expected)
+			}
+		})
+	}
+
+	if result := DetectBOM([]byte("text")); result != "" {
+		t.Errorf("DetectBOM() = %q, want empty for content without a BOM", result)
+	}
+}
+
+func TestDetect_UTF8(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"ASCII", "package main\n"},
+		{"multibyte", "こんにちは、世界"},
+		// The sample may end in the middle of a character
+		{"truncated", "こんにちは"[:14]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := Detect([]byte(tc.input)); result != "utf-8" {
+				t.Errorf("Detect() = %q, want utf-8", result)
+			}
+		})
+	}
+}
+
+func TestDetect_UTF16WithoutBOM(t *testing.T) {
+	text := "package main\n\nfunc main() {}\n"
+
+	for _, tc := range []struct {
+		name       string
+		endianness unicode.Endianness
+		expected   string
+	}{
+		{"little endian", unicode.LittleEndian, "utf-16le"},
+		{"big endian", unicode.BigEndian, "utf-16be"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := unicode.UTF16(tc.endianness, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(text))
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			if result := Detect(encoded); result != tc.expected {
+				t.Errorf("Detect() = %q, want %q", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDetect_Legacy(t *testing.T) {
+	japanese := "// 設定ファイルを読み込む\nfunc load() error {\n\treturn nil // エラーはありません\n}\n"
+	chineseSimplified := "// 读取配置文件，如果文件不存在就使用默认值\nfunc load() error {\n\treturn nil\n}\n"
+	chineseTraditional := "// 讀取設定檔案，如果檔案不存在就使用預設值\nfunc load() error {\n\treturn nil\n}\n"
+	korean := "// 설정 파일을 읽어 옵니다. 파일이 없으면 기본값을 사용합니다.\nfunc load() error {\n\treturn nil\n}\n"
+	western := "Ceci est un fichier en français.\nGröße, Übung, schön. Año, señor.\n"
+
+	testCases := []struct {
+		text     string
+		encoding string
+	}{
+		{japanese, "shift_jis"},
+		{japanese, "euc-jp"},
+		{japanese, "iso-2022-jp"},
+		{chineseSimplified, "gbk"},
+		{chineseTraditional, "big5"},
+		{korean, "euc-kr"},
+		{western, "windows-1252"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.encoding, func(t *testing.T) {
+			enc, err := htmlindex.Get(tc.encoding)
+			if err != nil {
+				t.Fatalf("Failed to get encoding: %v", err)
+			}
+			encoded, err := enc.NewEncoder().Bytes([]byte(tc.text))
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			if result := Detect(encoded); result != tc.encoding {
+				t.Errorf("Detect() = %q, want %q", result, tc.encoding)
+			}
+
+			// Detected names are accepted by NewConverter
+			converter, err := NewConverter(Detect(encoded))
+			if err != nil {
+				t.Fatalf("NewConverter failed: %v", err)
+			}
+			converted, err := converter.ConvertToUTF8(encoded)
+			if err != nil {
+				t.Fatalf("ConvertToUTF8 failed: %v", err)
+			}
+			if string(converted) != tc.text {
+				t.Errorf("Expected %q, got %q", tc.text, converted)
+			}
+		})
+	}
+}
+
+func TestDetect_Truncated(t *testing.T) {
+	enc, err := htmlindex.Get("shift_jis")
+	if err != nil {
+		t.Fatalf("Failed to get encoding: %v", err)
+	}
+	content, err := enc.NewEncoder().Bytes([]byte(strings.Repeat("日本語のテキスト\n", 100)))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// Cut in the middle of a character
+	if result := Detect(content[:len(content)-2]); result != "shift_jis" {
+		t.Errorf("Detect() = %q, want shift_jis", result)
+	}
+}
+
+func TestNewConverter_UTF32(t *testing.T) {
+	text := "UTF-32 テキスト"
+
+	testCases := []struct {
+		name       string
+		endianness utf32.Endianness
+		bom        utf32.BOMPolicy
+	}{
+		{"utf-32le", utf32.LittleEndian, utf32.IgnoreBOM},
+		{"utf-32be", utf32.BigEndian, utf32.IgnoreBOM},
+		{"utf-32", utf32.LittleEndian, utf32.UseBOM},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := utf32.UTF32(tc.endianness, tc.bom).NewEncoder().Bytes([]byte(text))
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			converter, err := NewConverter(tc.name)
+			if err != nil {
+				t.Fatalf("NewConverter failed: %v", err)
+			}
+			converted, err := converter.ConvertToUTF8(encoded)
+			if err != nil {
+				t.Fatalf("ConvertToUTF8 failed: %v", err)
+			}
+			if string(converted) != text {
+				t.Errorf("Expected %q, got %q", text, converted)
+			}
+		})
+	}
+}
+
+func TestIsWide(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{"utf-16le", true},
+		{"UTF-16BE", true},
+		{"utf-16", true},
+		{"utf-32", true},
+		{"utf_32le", true},
+		{"utf-8", false},
+		{"shift_jis", false},
+		{"unknown", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := IsWide(tc.name); result != tc.expected {
+				t.Errorf("IsWide(%q) = %v, want %v", tc.name, result, tc.expected)
+			}
+		})
+	}
+}
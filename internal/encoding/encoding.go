@@ -8,24 +8,38 @@ import (
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode/utf32"
 	"golang.org/x/text/transform"
 )
 
+// utf32Encodings are the UTF-32 encodings, which htmlindex doesn't support.
+// Without an explicit byte order, the BOM decides it (big-endian by default).
+var utf32Encodings = map[string]encoding.Encoding{
+	"utf-32":   utf32.UTF32(utf32.BigEndian, utf32.UseBOM),
+	"utf-32le": utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM),
+	"utf-32be": utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM),
+}
+
 // Converter is an interface for encoding conversion.
+// Implementations must be safe for concurrent use.
 type Converter interface {
 	ConvertToUTF8(content []byte) ([]byte, error)
+	NewDecoder() transform.Transformer // Decodes to UTF-8 while streaming (a new one per stream)
+	NewEncoder() transform.Transformer // Encodes UTF-8 while streaming (a new one per stream)
+	Name() string                      // Encoding name as specified (e.g., shift_jis)
 }
 
 // textConverter converts content from a specific encoding to UTF-8.
 type textConverter struct {
 	encodingName string
-	decoder      *encoding.Decoder
+	encoding     encoding.Encoding
 }
 
 // NewConverter creates a Converter from the specified encoding name.
 // If encodingName is an empty string, it returns nil (no conversion).
 // Uses the htmlindex package to support IANA standard encoding names.
-// Examples: shift_jis, euc-jp, iso-2022-jp, windows-1252, iso-8859-1, utf-8, etc.
+// UTF-32 (utf-32, utf-32le, utf-32be) is supported in addition.
+// Examples: shift_jis, euc-jp, iso-2022-jp, windows-1252, iso-8859-1, utf-8, utf-16le, etc.
 func NewConverter(encodingName string) (Converter, error) {
 	if encodingName == "" {
 		return nil, nil
@@ -40,20 +54,26 @@ func NewConverter(encodingName string) (Converter, error) {
 	}
 
 	// Get Encoding from IANA standard encoding name via htmlindex.Get()
-	enc, err := htmlindex.Get(normalizedName)
-	if err != nil {
-		return nil, fmt.Errorf("unsupported encoding: %s", encodingName)
+	// unless it is UTF-32
+	enc, ok := utf32Encodings[normalizedName]
+	if !ok {
+		var err error
+		enc, err = htmlindex.Get(normalizedName)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported encoding: %s", encodingName)
+		}
 	}
 
 	return &textConverter{
 		encodingName: encodingName,
-		decoder:      enc.NewDecoder(),
+		encoding:     enc,
 	}, nil
 }
 
 // ConvertToUTF8 converts the input byte slice to UTF-8.
+// A decoder is created for each call since decoders hold state.
 func (c *textConverter) ConvertToUTF8(content []byte) ([]byte, error) {
-	reader := transform.NewReader(bytes.NewReader(content), c.decoder)
+	reader := transform.NewReader(bytes.NewReader(content), c.NewDecoder())
 	utf8Content, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("encoding conversion failed: %w", err)
@@ -61,6 +81,37 @@ func (c *textConverter) ConvertToUTF8(content []byte) ([]byte, error) {
 	return utf8Content, nil
 }
 
+// NewDecoder returns a new transformer decoding the encoding to UTF-8.
+func (c *textConverter) NewDecoder() transform.Transformer {
+	return c.encoding.NewDecoder()
+}
+
+// NewEncoder returns a new transformer encoding UTF-8 to the encoding.
+// Characters the encoding can't represent are encoded as '?'.
+func (c *textConverter) NewEncoder() transform.Transformer {
+	return unsupportedReplacer{c.encoding.NewEncoder()}
+}
+
+// Name returns the encoding name as specified when the converter was created.
+func (c *textConverter) Name() string {
+	return c.encodingName
+}
+
+// IsWide reports whether the encoding is UTF-16 or UTF-32, whose content
+// has NUL bytes in ASCII characters and must be decoded before binary detection.
+func IsWide(encodingName string) bool {
+	normalizedName := strings.ToLower(strings.ReplaceAll(encodingName, "_", "-"))
+	if _, ok := utf32Encodings[normalizedName]; ok {
+		return true
+	}
+	enc, err := htmlindex.Get(normalizedName)
+	if err != nil {
+		return false
+	}
+	name, _ := htmlindex.Name(enc)
+	return name == "utf-16le" || name == "utf-16be"
+}
+
 // NormalizeExtension removes leading dot and converts to lowercase.
 // Examples: ".TXT" -> "txt", "log" -> "log", ".Md" -> "md"
 func NormalizeExtension(ext string) string {
@@ -64,6 +64,18 @@ func TestNewConverter_ValidEncodings(t *testing.T) {
 	}
 }
 
+func TestConverter_Name(t *testing.T) {
+	converter, err := NewConverter("Shift_JIS")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	// Name is kept as specified
+	if converter.Name() != "Shift_JIS" {
+		t.Errorf("Name() = %q, want %q", converter.Name(), "Shift_JIS")
+	}
+}
+
 func TestNewConverter_NameNormalization(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -0,0 +1,202 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+// InvalidBytes specifies how byte sequences that are invalid in the encoding
+// of a file (UTF-8 for unconverted files) are handled.
+type InvalidBytes int
+
+const (
+	// InvalidReplace replaces each invalid byte with U+FFFD.
+	InvalidReplace InvalidBytes = iota
+	// InvalidError fails with an *InvalidBytesError.
+	InvalidError
+	// InvalidSkipFile fails with an *InvalidBytesError like InvalidError,
+	// for the caller to skip the file.
+	InvalidSkipFile
+	// InvalidEscape writes each invalid byte as \xHH.
+	InvalidEscape
+	// InvalidKeep leaves invalid bytes of unconverted files as they are, and
+	// lets decoders replace them without counting. Used for binary content.
+	InvalidKeep
+)
+
+// ParseInvalidBytes parses an invalid bytes policy name (replace, error, skip-file, escape).
+func ParseInvalidBytes(name string) (InvalidBytes, error) {
+	switch strings.ToLower(name) {
+	case "", "replace":
+		return InvalidReplace, nil
+	case "error":
+		return InvalidError, nil
+	case "skip-file":
+		return InvalidSkipFile, nil
+	case "escape":
+		return InvalidEscape, nil
+	}
+	return InvalidReplace, fmt.Errorf("invalid --invalid-bytes policy: %s (expected replace, error, skip-file or escape)", name)
+}
+
+// InvalidBytesError is returned when content has an invalid byte sequence
+// with InvalidError or InvalidSkipFile.
+type InvalidBytesError struct {
+	Encoding string // Encoding name ("utf-8" for unconverted content)
+	Offset   int64  // Offset of the first invalid byte in the content
+}
+
+func (e *InvalidBytesError) Error() string {
+	return fmt.Sprintf("invalid %s byte sequence at offset %d", e.Encoding, e.Offset)
+}
+
+// replacement is U+FFFD encoded in UTF-8.
+const replacement = "\uFFFD"
+
+// validator decodes content to UTF-8 (with a decoder) or checks that it is
+// valid UTF-8 (without), handling invalid bytes according to the policy.
+type validator struct {
+	decoder  transform.Transformer // nil for UTF-8 content
+	encoding string
+	policy   InvalidBytes
+	invalid  int64  // Invalid bytes replaced or escaped
+	offset   int64  // Source bytes consumed before the current call
+	pending  []byte // Output of a consumed character that didn't fit in dst
+}
+
+// newValidator creates a validator for content in the converter's encoding
+// (UTF-8 if converter is nil).
+func newValidator(converter Converter, policy InvalidBytes) *validator {
+	v := &validator{encoding: "utf-8", policy: policy}
+	if converter != nil {
+		v.decoder = converter.NewDecoder()
+		v.encoding = converter.Name()
+	}
+	return v
+}
+
+func (v *validator) Reset() {
+	if v.decoder != nil {
+		v.decoder.Reset()
+	}
+	v.invalid, v.offset, v.pending = 0, 0, nil
+}
+
+func (v *validator) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	defer func() { v.offset += int64(nSrc) }()
+
+	if len(v.pending) > 0 {
+		n := copy(dst, v.pending)
+		v.pending = v.pending[n:]
+		if len(v.pending) > 0 {
+			return n, 0, transform.ErrShortDst
+		}
+		nDst = n
+	}
+
+	if v.decoder == nil {
+		return v.transformUTF8(dst, src, atEOF, nDst)
+	}
+	return v.transformDecoded(dst, src, atEOF, nDst)
+}
+
+// transformUTF8 copies valid UTF-8 and handles invalid bytes.
+func (v *validator) transformUTF8(dst, src []byte, atEOF bool, nDst int) (int, int, error) {
+	nSrc := 0
+	for nSrc < len(src) {
+		if b := src[nSrc]; b < utf8.RuneSelf {
+			if nDst == len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = b
+			nDst++
+			nSrc++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(src[nSrc:])
+		if r == utf8.RuneError && size <= 1 {
+			if !atEOF && !utf8.FullRune(src[nSrc:]) {
+				// The rest of the sequence may be in the next buffer
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			out, err := v.handle(src[nSrc:nSrc+1], nSrc)
+			if err != nil {
+				return nDst, nSrc, err
+			}
+			if nDst+len(out) > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], out)
+			v.invalid++
+			nSrc++
+			continue
+		}
+
+		if nDst+size > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], src[nSrc:nSrc+size])
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+
+// transformDecoded decodes one character at a time, so that the source bytes
+// of each U+FFFD written by the decoder for an invalid sequence are known.
+func (v *validator) transformDecoded(dst, src []byte, atEOF bool, nDst int) (int, int, error) {
+	var buf [utf8.UTFMax]byte
+	nSrc := 0
+	for nSrc < len(src) {
+		// A buffer of 3 bytes holds U+FFFD alone (or up to 3 other
+		// characters), and one of 4 bytes is only used for a 4-byte character
+		n, m, err := v.decoder.Transform(buf[:len(replacement)], src[nSrc:], atEOF)
+		if err == transform.ErrShortDst && n == 0 {
+			n, m, err = v.decoder.Transform(buf[:], src[nSrc:], atEOF)
+		}
+		if n == 0 && m == 0 {
+			if err == nil || err == transform.ErrShortDst {
+				err = transform.ErrShortSrc
+			}
+			return nDst, nSrc, err
+		}
+
+		out := buf[:n]
+		if string(out) == replacement {
+			out, err = v.handle(src[nSrc:nSrc+m], nSrc)
+			if err != nil {
+				return nDst, nSrc, err
+			}
+			v.invalid += int64(m)
+		}
+		nSrc += m
+
+		// The decoder has consumed the bytes, so output that doesn't fit is kept
+		written := copy(dst[nDst:], out)
+		nDst += written
+		if written < len(out) {
+			v.pending = append([]byte(nil), out[written:]...)
+			return nDst, nSrc, transform.ErrShortDst
+		}
+	}
+	return nDst, nSrc, nil
+}
+
+// handle returns the output for invalid bytes at offset i of the current
+// source, or an error if they aren't allowed.
+func (v *validator) handle(raw []byte, i int) ([]byte, error) {
+	switch v.policy {
+	case InvalidError, InvalidSkipFile:
+		return nil, &InvalidBytesError{Encoding: v.encoding, Offset: v.offset + int64(i)}
+	case InvalidEscape:
+		var escaped []byte
+		for _, b := range raw {
+			escaped = fmt.Appendf(escaped, `\x%02X`, b)
+		}
+		return escaped, nil
+	}
+	return []byte(replacement), nil
+}
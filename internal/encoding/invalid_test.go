@@ -0,0 +1,129 @@
+package encoding
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseInvalidBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected InvalidBytes
+	}{
+		{"", InvalidReplace},
+		{"replace", InvalidReplace},
+		{"error", InvalidError},
+		{"skip-file", InvalidSkipFile},
+		{"ESCAPE", InvalidEscape},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseInvalidBytes(tc.name)
+			if err != nil {
+				t.Fatalf("ParseInvalidBytes failed: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+
+	_, err := ParseInvalidBytes("keep")
+	if err == nil || err.Error() != "invalid --invalid-bytes policy: keep (expected replace, error, skip-file or escape)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestNewReader_InvalidBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoding string
+		input    string
+		policy   InvalidBytes
+		expected string
+		invalid  int64
+	}{
+		{"valid UTF-8", "", "あ\r\nb", InvalidError, "あ\nb", 0},
+		{"UTF-8 replace", "", "a\xff\xfeb\r\n\x80", InvalidReplace, "a��b\n�", 3},
+		{"UTF-8 escape", "", "a\xffb\xe3\x81", InvalidEscape, `a\xFFb\xE3\x81`, 3},
+		{"UTF-8 keep", "", "a\xffb", InvalidKeep, "a\xffb", 0},
+		{"UTF-8 BOM", "", "\xEF\xBB\xBFa\xff", InvalidReplace, "a�", 1},
+		{"Shift_JIS valid", "shift_jis", "\x82\xa0\r\n\x88\x9f", InvalidError, "あ\n亜", 0},
+		{"Shift_JIS replace", "shift_jis", "\x82\xa0\xa0b", InvalidReplace, "あ�b", 1},
+		{"Shift_JIS escape", "shift_jis", "a\x85\x40b", InvalidEscape, `a\x85\x40b`, 2},
+		{"Shift_JIS keep", "shift_jis", "a\x85\x40b", InvalidKeep, "a�b", 0},
+		{"UTF-16 escape", "utf-16le", "a\x00\x00\xd8b\x00", InvalidEscape, `a\x00\xD8b`, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var converter Converter
+			if tc.encoding != "" {
+				var err error
+				converter, err = NewConverter(tc.encoding)
+				if err != nil {
+					t.Fatalf("NewConverter failed: %v", err)
+				}
+			}
+
+			// One byte at a time to split sequences across buffers
+			for _, split := range []bool{false, true} {
+				var src io.Reader = strings.NewReader(tc.input)
+				if split {
+					src = iotest.OneByteReader(src)
+				}
+				reader := NewReader(src, converter, tc.policy, EOLLF)
+				result, err := io.ReadAll(iotest.OneByteReader(reader))
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if string(result) != tc.expected {
+					t.Errorf("Expected %q, got %q", tc.expected, result)
+				}
+				if reader.Invalid() != tc.invalid {
+					t.Errorf("Expected %d invalid bytes, got %d", tc.invalid, reader.Invalid())
+				}
+			}
+		})
+	}
+}
+
+func TestNewReader_InvalidBytesError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoding string
+		input    string
+		policy   InvalidBytes
+		expected string
+	}{
+		{"UTF-8", "", strings.Repeat("あ", 2000) + "\xff", InvalidError, "invalid utf-8 byte sequence at offset 6000"},
+		{"UTF-8 truncated", "", "abc\xe3\x81", InvalidSkipFile, "invalid utf-8 byte sequence at offset 3"},
+		{"Shift_JIS", "shift_jis", strings.Repeat("a", 5000) + "\x82\xa0\x85\x40", InvalidError, "invalid shift_jis byte sequence at offset 5002"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var converter Converter
+			if tc.encoding != "" {
+				var err error
+				converter, err = NewConverter(tc.encoding)
+				if err != nil {
+					t.Fatalf("NewConverter failed: %v", err)
+				}
+			}
+
+			_, err := io.ReadAll(NewReader(strings.NewReader(tc.input), converter, tc.policy, EOLLF))
+			var invalidErr *InvalidBytesError
+			if !errors.As(err, &invalidErr) {
+				t.Fatalf("Expected InvalidBytesError, got %v", err)
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, err.Error())
+			}
+		})
+	}
+}
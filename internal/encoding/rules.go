@@ -0,0 +1,130 @@
+package encoding
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Rule selects the encoding of files matching a pattern.
+type Rule struct {
+	Pattern   string    // doublestar pattern, or an extension without glob characters (e.g., "txt")
+	Converter Converter // nil for UTF-8
+	extension string    // normalized extension when Pattern is an extension
+}
+
+// Rules selects the encoding of files by the first matching rule,
+// falling back to a default encoding for unmatched files.
+type Rules struct {
+	Rules   []Rule
+	Default Converter // for files matching no rule (nil for UTF-8)
+}
+
+// ParseRules parses encoding rules in "pattern1:encoding1,pattern2:encoding2"
+// format and a default encoding for files matching no rule.
+// Patterns are doublestar globs matched against paths relative to the target
+// directory (like --include and --exclude). A pattern without '/' or glob
+// characters is an extension as in ParseEncodingMap (e.g., "txt" or ".TXT").
+// Commas inside braces (e.g., "*.{txt,csv}") don't separate rules.
+// Returns nil if both are empty. Validates all patterns and encoding names
+// upfront (fail-fast).
+func ParseRules(rulesStr, defaultEncoding string) (*Rules, error) {
+	if strings.TrimSpace(rulesStr) == "" && defaultEncoding == "" {
+		return nil, nil
+	}
+
+	rules := &Rules{}
+	if defaultEncoding != "" {
+		converter, err := NewConverter(defaultEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default encoding: %w", err)
+		}
+		rules.Default = converter
+	}
+
+	if strings.TrimSpace(rulesStr) == "" {
+		return rules, nil
+	}
+	for _, entry := range splitRules(rulesStr) {
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid encoding rule: %s (expected format: pattern:encoding)", entry)
+		}
+		pattern := strings.TrimSpace(entry[:i])
+		encodingName := strings.TrimSpace(entry[i+1:])
+
+		if pattern == "" {
+			return nil, fmt.Errorf("empty pattern in encoding rule: %s", entry)
+		}
+		if encodingName == "" {
+			return nil, fmt.Errorf("empty encoding name in encoding rule: %s", entry)
+		}
+
+		rule := Rule{Pattern: pattern}
+		if isExtension(pattern) {
+			rule.extension = NormalizeExtension(pattern)
+		} else if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid pattern in encoding rule: %s", entry)
+		}
+
+		// Create converter (validates encoding name)
+		converter, err := NewConverter(encodingName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encoding for pattern '%s': %w", pattern, err)
+		}
+		rule.Converter = converter
+
+		rules.Rules = append(rules.Rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Match returns the converter of the first rule matching the path (relative
+// to the target directory, with forward slashes) and true, or the default
+// converter and false if no rule matches.
+func (r *Rules) Match(relPath string) (Converter, bool) {
+	for _, rule := range r.Rules {
+		if rule.matches(relPath) {
+			return rule.Converter, true
+		}
+	}
+	return r.Default, false
+}
+
+// matches reports whether the rule applies to the path.
+func (rule Rule) matches(relPath string) bool {
+	if rule.extension != "" {
+		return NormalizeExtension(path.Ext(relPath)) == rule.extension
+	}
+	matched, err := doublestar.Match(rule.Pattern, relPath)
+	return err == nil && matched
+}
+
+// isExtension reports whether a rule pattern is an extension rather than a
+// glob: it has no path separator or glob characters.
+func isExtension(pattern string) bool {
+	return !strings.ContainsAny(pattern, "/*?[{\\")
+}
+
+// splitRules splits rules on commas outside braces.
+func splitRules(rulesStr string) []string {
+	var entries []string
+	depth, start := 0, 0
+	for i, c := range rulesStr {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth = max(0, depth-1)
+		case ',':
+			if depth == 0 {
+				entries = append(entries, strings.TrimSpace(rulesStr[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(entries, strings.TrimSpace(rulesStr[start:]))
+}
@@ -0,0 +1,93 @@
+package encoding
+
+import (
+	"testing"
+)
+
+func TestParseRules_Empty(t *testing.T) {
+	rules, err := ParseRules("", "")
+	if err != nil {
+		t.Errorf("Expected no error for empty rules, got %v", err)
+	}
+	if rules != nil {
+		t.Error("Expected nil rules for empty string")
+	}
+}
+
+func TestRules_Match(t *testing.T) {
+	rules, err := ParseRules("legacy/**/*.txt:shift_jis, docs/**:utf-8, *.{csv,tsv}:windows-1252, .LOG:euc-jp, legacy/**:euc-kr", "gbk")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	testCases := []struct {
+		path     string
+		expected string // Encoding name ("utf-8" for nil)
+		matched  bool
+	}{
+		{"legacy/a/b/readme.txt", "shift_jis", true},
+		{"legacy/readme.txt", "shift_jis", true},
+		// First match wins
+		{"legacy/data.csv", "euc-kr", true},
+		{"docs/readme.txt", "utf-8", true},
+		{"data.csv", "windows-1252", true},
+		{"data.tsv", "windows-1252", true},
+		{"sub/data.csv", "gbk", false},
+		// Extensions match at any depth and ignore case
+		{"logs/app.log", "euc-jp", true},
+		{"APP.LOG", "euc-jp", true},
+		{"main.go", "gbk", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			converter, matched := rules.Match(tc.path)
+			name := "utf-8"
+			if converter != nil {
+				name = converter.Name()
+			}
+			if name != tc.expected || matched != tc.matched {
+				t.Errorf("Match(%q) = %s, %v; want %s, %v", tc.path, name, matched, tc.expected, tc.matched)
+			}
+		})
+	}
+}
+
+func TestParseRules_DefaultOnly(t *testing.T) {
+	rules, err := ParseRules("", "shift_jis")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	converter, matched := rules.Match("any/file.txt")
+	if matched {
+		t.Error("Expected no rule to match")
+	}
+	if converter == nil || converter.Name() != "shift_jis" {
+		t.Errorf("Expected the default converter, got %v", converter)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	testCases := []struct {
+		name            string
+		input           string
+		defaultEncoding string
+	}{
+		{"no colon", "txt", ""},
+		{"missing pattern", ":shift_jis", ""},
+		{"missing encoding", "legacy/**:", ""},
+		{"empty entry", "txt:shift_jis,", ""},
+		{"invalid pattern", "legacy/[a-:shift_jis", ""},
+		{"invalid encoding", "legacy/**:invalid-encoding", ""},
+		{"invalid default encoding", "", "invalid-encoding"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseRules(tc.input, tc.defaultEncoding); err == nil {
+				t.Errorf("ParseRules(%q, %q) should return error, got nil", tc.input, tc.defaultEncoding)
+			}
+		})
+	}
+}
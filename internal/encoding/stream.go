@@ -0,0 +1,257 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+// utf8BOM is the UTF-8 byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Reader reads content converted by NewReader.
+type Reader struct {
+	reader    io.Reader
+	validator *validator // nil with InvalidKeep
+}
+
+// NewReader returns a reader converting content read from r to UTF-8 with
+// the BOM removed and line endings normalized to LF, processing it in
+// bounded buffers. It is equivalent to ConvertToUTF8, RemoveBOM and
+// NormalizeNewlines applied in order. converter may be nil for UTF-8 content.
+// Byte sequences invalid in the encoding are handled according to invalid;
+// with InvalidError and InvalidSkipFile, Read fails with an *InvalidBytesError.
+// With EOLPreserve, line endings are left as they are (CRLF is written by
+// NewWriter, so EOLCRLF normalizes them to LF like EOLLF).
+func NewReader(r io.Reader, converter Converter, invalid InvalidBytes, eol EOL) *Reader {
+	reader := &Reader{}
+	var transformers []transform.Transformer
+	if invalid != InvalidKeep {
+		reader.validator = newValidator(converter, invalid)
+		transformers = append(transformers, reader.validator)
+	} else if converter != nil {
+		transformers = append(transformers, converter.NewDecoder())
+	}
+	transformers = append(transformers, NewBOMRemover())
+	if eol != EOLPreserve {
+		transformers = append(transformers, NewNewlineNormalizer())
+	}
+	reader.reader = transform.NewReader(r, transform.Chain(transformers...))
+	return reader
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+// Invalid returns the number of invalid bytes replaced or escaped so far.
+func (r *Reader) Invalid() int64 {
+	if r.validator == nil {
+		return 0
+	}
+	return r.validator.invalid
+}
+
+// bomRemover removes a UTF-8 BOM at the start of the stream.
+type bomRemover struct {
+	started bool
+}
+
+// NewBOMRemover returns a transformer removing a UTF-8 BOM at the start of
+// the stream, like RemoveBOM.
+func NewBOMRemover() transform.Transformer {
+	return &bomRemover{}
+}
+
+func (t *bomRemover) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if !t.started {
+		if len(src) < len(utf8BOM) && !atEOF && bytes.HasPrefix(utf8BOM, src) {
+			// Not enough bytes to tell whether this is a BOM
+			return 0, 0, transform.ErrShortSrc
+		}
+		t.started = true
+		if bytes.HasPrefix(src, utf8BOM) {
+			nSrc = len(utf8BOM)
+		}
+	}
+
+	n := copy(dst, src[nSrc:])
+	nDst, nSrc = n, nSrc+n
+	if nSrc < len(src) {
+		err = transform.ErrShortDst
+	}
+	return nDst, nSrc, err
+}
+
+func (t *bomRemover) Reset() {
+	t.started = false
+}
+
+// newlineNormalizer converts CRLF and CR line endings to LF.
+type newlineNormalizer struct {
+	transform.NopResetter
+}
+
+// NewNewlineNormalizer returns a transformer converting all line endings to
+// LF, like NormalizeNewlines.
+func NewNewlineNormalizer() transform.Transformer {
+	return newlineNormalizer{}
+}
+
+func (newlineNormalizer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		// Copy up to the next CR
+		end := len(src)
+		if i := bytes.IndexByte(src[nSrc:], '\r'); i >= 0 {
+			end = nSrc + i
+		}
+		n := copy(dst[nDst:], src[nSrc:end])
+		nDst += n
+		nSrc += n
+		if nSrc < end {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		if nSrc == len(src) {
+			break
+		}
+
+		// A CR at the end may be followed by LF in the next buffer
+		if nSrc+1 == len(src) && !atEOF {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+		if nDst == len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = '\n'
+		nDst++
+		nSrc++
+		if nSrc < len(src) && src[nSrc] == '\n' {
+			nSrc++
+		}
+	}
+	return nDst, nSrc, nil
+}
+
+// EOL specifies the line endings of the output.
+type EOL int
+
+const (
+	// EOLLF normalizes all line endings to LF.
+	EOLLF EOL = iota
+	// EOLCRLF normalizes all line endings to LF, and writes them as CRLF.
+	EOLCRLF
+	// EOLPreserve leaves the line endings of file contents as they are.
+	// The rest of the output uses LF.
+	EOLPreserve
+)
+
+// ParseEOL parses a line ending name (lf, crlf, preserve).
+func ParseEOL(name string) (EOL, error) {
+	switch strings.ToLower(name) {
+	case "", "lf":
+		return EOLLF, nil
+	case "crlf":
+		return EOLCRLF, nil
+	case "preserve":
+		return EOLPreserve, nil
+	}
+	return EOLLF, fmt.Errorf("invalid line ending: %s (expected lf, crlf or preserve)", name)
+}
+
+// NewWriter returns a writer encoding UTF-8 content written to it with the
+// converter's encoding (nil for UTF-8) and writing LF as CRLF with EOLCRLF,
+// processing it in bounded buffers. Close must be called to write the rest
+// of the content; it doesn't close w.
+func NewWriter(w io.Writer, converter Converter, eol EOL) io.WriteCloser {
+	var transformers []transform.Transformer
+	if eol == EOLCRLF {
+		transformers = append(transformers, crlfConverter{})
+	}
+	if converter != nil {
+		transformers = append(transformers, converter.NewEncoder())
+	}
+	if len(transformers) == 0 {
+		return nopCloser{w}
+	}
+	return transform.NewWriter(w, transform.Chain(transformers...))
+}
+
+// nopCloser is a writer whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// crlfConverter converts LF to CRLF.
+type crlfConverter struct {
+	transform.NopResetter
+}
+
+func (crlfConverter) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		// Copy up to the next LF
+		end := len(src)
+		if i := bytes.IndexByte(src[nSrc:], '\n'); i >= 0 {
+			end = nSrc + i
+		}
+		n := copy(dst[nDst:], src[nSrc:end])
+		nDst += n
+		nSrc += n
+		if nSrc < end {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		if nSrc == len(src) {
+			break
+		}
+
+		if nDst+2 > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst], dst[nDst+1] = '\r', '\n'
+		nDst += 2
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+// repertoireError is the error of x/text encoders for a character the
+// encoding can't represent.
+type repertoireError interface {
+	Replacement() byte
+}
+
+// unsupportedReplacer encodes characters the encoding can't represent as '?',
+// rather than the ASCII SUB control character of encoding.ReplaceUnsupported.
+type unsupportedReplacer struct {
+	transform.Transformer
+}
+
+func (t unsupportedReplacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	nDst, nSrc, err = t.Transformer.Transform(dst, src, atEOF)
+	for err != nil {
+		if _, ok := err.(repertoireError); !ok {
+			return nDst, nSrc, err
+		}
+		if nDst == len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = '?'
+		nDst++
+		_, size := utf8.DecodeRune(src[nSrc:])
+		nSrc += size
+
+		err = nil
+		if nSrc < len(src) {
+			n, m, terr := t.Transformer.Transform(dst[nDst:], src[nSrc:], atEOF)
+			nDst, nSrc, err = nDst+n, nSrc+m, terr
+		}
+	}
+	return nDst, nSrc, nil
+}
@@ -0,0 +1,162 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestNewReader(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"BOM only", "\xEF\xBB\xBF"},
+		{"partial BOM", "\xEF\xBB"},
+		{"BOM with CRLF", "\xEF\xBB\xBFline1\r\nline2\r\n"},
+		{"BOM not at start", "a\xEF\xBB\xBFb"},
+		{"mixed line endings", "line1\r\nline2\nline3\rline4"},
+		{"CR at end", "line1\r"},
+		{"consecutive CRs", "\r\r\n\r\r"},
+		{"invalid UTF-8 kept", "\xff\xfe\r\n\x80"},
+		{"long", strings.Repeat("0123456789\r\n", 10000) + "\r"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			withoutBOM, _ := RemoveBOM([]byte(tc.input))
+			expected := NormalizeNewlines(withoutBOM)
+
+			// One byte at a time to split CRLF and the BOM across buffers
+			for _, reader := range []io.Reader{
+				strings.NewReader(tc.input),
+				iotest.OneByteReader(strings.NewReader(tc.input)),
+			} {
+				result, err := io.ReadAll(iotest.OneByteReader(NewReader(reader, nil, InvalidKeep, EOLLF)))
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if !bytes.Equal(result, expected) {
+					t.Errorf("Expected %q, got %q", expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestNewReader_Converter(t *testing.T) {
+	converter, err := NewConverter("shift_jis")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	original := strings.Repeat("こんにちは\r\n世界\r", 1000)
+	shiftJISBytes, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(original))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	result, err := io.ReadAll(NewReader(iotest.OneByteReader(bytes.NewReader(shiftJISBytes)), converter, InvalidReplace, EOLLF))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	expected := strings.Repeat("こんにちは\n世界\n", 1000)
+	if string(result) != expected {
+		t.Errorf("Unexpected content: %q...", result[:min(len(result), 40)])
+	}
+}
+
+func TestNewReader_PreserveEOL(t *testing.T) {
+	input := "\xEF\xBB\xBFline1\r\nline2\rline3\n"
+
+	result, err := io.ReadAll(NewReader(iotest.OneByteReader(strings.NewReader(input)), nil, InvalidReplace, EOLPreserve))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	expected := "line1\r\nline2\rline3\n"
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestParseEOL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected EOL
+	}{
+		{"", EOLLF},
+		{"lf", EOLLF},
+		{"CRLF", EOLCRLF},
+		{"preserve", EOLPreserve},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseEOL(tc.name)
+			if err != nil {
+				t.Fatalf("ParseEOL failed: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+
+	_, err := ParseEOL("cr")
+	if err == nil || err.Error() != "invalid line ending: cr (expected lf, crlf or preserve)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	shiftJIS, err := NewConverter("shift_jis")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+	utf16, err := NewConverter("utf-16le")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		converter Converter
+		eol       EOL
+		input     string
+		expected  string
+	}{
+		{"UTF-8 LF", nil, EOLLF, "a\nb\r\n", "a\nb\r\n"},
+		{"UTF-8 CRLF", nil, EOLCRLF, "a\n\nb", "a\r\n\r\nb"},
+		{"Shift_JIS CRLF", shiftJIS, EOLCRLF, "日本語\n", "\x93\xfa\x96\x7b\x8c\xea\r\n"},
+		{"Shift_JIS unsupported", shiftJIS, EOLLF, "a😀bé\n", "a?b?\n"},
+		{"UTF-16 CRLF", utf16, EOLCRLF, "a\n", "a\x00\r\x00\n\x00"},
+		{"long", shiftJIS, EOLCRLF, strings.Repeat("あ\n", 10000), strings.Repeat("\x82\xa0\r\n", 10000)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// One byte at a time to split characters across writes
+			var buf bytes.Buffer
+			writer := NewWriter(&buf, tc.converter, tc.eol)
+			for i := 0; i < len(tc.input); i++ {
+				if _, err := writer.Write([]byte{tc.input[i]}); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			if buf.String() != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
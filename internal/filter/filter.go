@@ -1,11 +1,13 @@
 package filter
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
+	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
@@ -15,28 +17,142 @@ type Filter interface {
 }
 
 // GitignoreFilter filters files based on .gitignore patterns.
+// Besides the root .gitignore, every .gitignore found in a subdirectory is
+// loaded the first time a path inside that directory is checked, and its
+// patterns are scoped to that directory.
+// Patterns from core.excludesFile (or the global ignore file) and
+// .git/info/exclude are also applied, with lower priority than .gitignore.
+// When rootDir is inside a git worktree, all patterns are evaluated relative
+// to the worktree root, so rules from parent directories apply as well.
 type GitignoreFilter struct {
-	matcher gitignore.Matcher
-	rootDir string
+	patterns []gitignore.Pattern // In ascending order of priority (last wins)
+	loaded   map[string]bool     // Directories (slash-separated, relative to repoRoot) already read
+	rootDir  string
+	repoRoot string // Worktree root (rootDir itself when not inside a repository)
 }
 
 // NewGitignoreFilter creates a new GitignoreFilter.
-// If .gitignore doesn't exist, returns a filter that includes everything.
+// If .gitignore doesn't exist, returns a filter that includes everything
+// until a nested .gitignore is found.
 func NewGitignoreFilter(rootDir string) (*GitignoreFilter, error) {
-	gitignorePath := filepath.Join(rootDir, ".gitignore")
+	repoRoot, gitDir := FindRepositoryRoot(rootDir)
+	if repoRoot == "" {
+		repoRoot = rootDir
+	}
+
+	f := &GitignoreFilter{
+		loaded:   make(map[string]bool),
+		rootDir:  rootDir,
+		repoRoot: repoRoot,
+	}
+
+	// Load exclude files first (lowest priority)
+	excludePatterns, err := loadExcludePatterns(repoRoot, gitDir)
+	if err != nil {
+		return nil, err
+	}
+	f.patterns = append(f.patterns, excludePatterns...)
+
+	// Read .gitignore files from the worktree root down to rootDir eagerly
+	// so that read errors are reported upfront
+	if err := f.loadDir(nil); err != nil {
+		return nil, err
+	}
+	if relRoot, err := filepath.Rel(repoRoot, rootDir); err == nil && relRoot != "." {
+		parts := strings.Split(filepath.ToSlash(relRoot), "/")
+		for i := 1; i <= len(parts); i++ {
+			if err := f.loadDir(parts[:i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// FindRepositoryRoot walks up from dir to find the enclosing git worktree.
+// It returns the worktree root and the git directory holding info/exclude
+// and config (following "gitdir:" files used by worktrees and submodules).
+// Both are empty if dir is not inside a git worktree.
+func FindRepositoryRoot(dir string) (string, string) {
+	current := dir
+	for {
+		dotGit := filepath.Join(current, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return current, dotGit
+			}
+			if gitDir := readGitDirFile(dotGit); gitDir != "" {
+				return current, gitDir
+			}
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", ""
+		}
+		current = parent
+	}
+}
+
+// readGitDirFile resolves a ".git" file ("gitdir: <path>") to the common git
+// directory. Returns an empty string if the file is not a valid gitdir file.
+func readGitDirFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return ""
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+
+	// Linked worktrees share info/exclude and config with the main repository
+	if commonDir, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(commonDir))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		return filepath.Clean(common)
+	}
 
-	// Check if .gitignore exists
-	if _, err := os.Stat(gitignorePath); os.IsNotExist(err) {
-		// No .gitignore file, return nil matcher (include everything)
-		return &GitignoreFilter{
-			matcher: nil,
-			rootDir: rootDir,
-		}, nil
+	return gitDir
+}
+
+// loadDir reads the .gitignore file in the directory given by its path
+// components (relative to root) and appends its patterns.
+func (f *GitignoreFilter) loadDir(dir []string) error {
+	key := strings.Join(dir, "/")
+	if f.loaded[key] {
+		return nil
 	}
+	f.loaded[key] = true
+
+	gitignorePath := filepath.Join(append([]string{f.repoRoot}, dir...)...)
+	gitignorePath = filepath.Join(gitignorePath, ".gitignore")
 
-	// Read .gitignore patterns
-	data, err := os.ReadFile(gitignorePath)
+	patterns, err := readPatterns(gitignorePath, dir)
 	if err != nil {
+		return err
+	}
+	f.patterns = append(f.patterns, patterns...)
+
+	return nil
+}
+
+// readPatterns reads gitignore patterns from the file, scoped to domain.
+// A missing file yields no patterns.
+func readPatterns(path string, domain []string) ([]gitignore.Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -46,25 +162,102 @@ func NewGitignoreFilter(rootDir string) (*GitignoreFilter, error) {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
 	}
 
-	matcher := gitignore.NewMatcher(patterns)
+	return patterns, nil
+}
 
-	return &GitignoreFilter{
-		matcher: matcher,
-		rootDir: rootDir,
-	}, nil
+// loadExcludePatterns loads patterns that git applies in addition to .gitignore,
+// in ascending order of priority:
+//  1. core.excludesFile, or $XDG_CONFIG_HOME/git/ignore when it is not set
+//  2. .git/info/exclude
+//
+// gitDir may be empty when not inside a repository.
+func loadExcludePatterns(repoRoot, gitDir string) ([]gitignore.Pattern, error) {
+	var patterns []gitignore.Pattern
+
+	if excludesFile := findExcludesFile(repoRoot, gitDir); excludesFile != "" {
+		ps, err := readPatterns(excludesFile, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read excludes file %s: %w", excludesFile, err)
+		}
+		patterns = append(patterns, ps...)
+	}
+
+	if gitDir != "" {
+		infoExclude := filepath.Join(gitDir, "info", "exclude")
+		ps, err := readPatterns(infoExclude, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", infoExclude, err)
+		}
+		patterns = append(patterns, ps...)
+	}
+
+	return patterns, nil
 }
 
-// ShouldInclude returns true if the file should be included.
-func (f *GitignoreFilter) ShouldInclude(path string, isDir bool) bool {
-	if f.matcher == nil {
-		return true
+// findExcludesFile returns the path of the global excludes file.
+// core.excludesFile is looked up in the system, global and repository config
+// (later ones override earlier ones), falling back to git's default location.
+func findExcludesFile(repoRoot, gitDir string) string {
+	excludesFile := ""
+	for _, cfg := range loadGitConfigs(gitDir) {
+		if value := cfg.Raw.Section("core").Option("excludesfile"); value != "" {
+			excludesFile = value
+		}
 	}
 
-	// Get relative path from root
-	relPath, err := filepath.Rel(f.rootDir, path)
+	home, _ := os.UserHomeDir()
+	if excludesFile == "" {
+		xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+		if xdgConfigHome == "" {
+			if home == "" {
+				return ""
+			}
+			xdgConfigHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(xdgConfigHome, "git", "ignore")
+	}
+
+	// Expand "~/" like git does
+	if strings.HasPrefix(excludesFile, "~/") && home != "" {
+		excludesFile = filepath.Join(home, excludesFile[2:])
+	}
+	if !filepath.IsAbs(excludesFile) {
+		excludesFile = filepath.Join(repoRoot, excludesFile)
+	}
+
+	return excludesFile
+}
+
+// loadGitConfigs loads the system, global and repository git config in
+// ascending order of priority. A missing or unparsable config is left out;
+// it is not ours to validate.
+func loadGitConfigs(gitDir string) []*gitconfig.Config {
+	var configs []*gitconfig.Config
+	for _, scope := range []gitconfig.Scope{gitconfig.SystemScope, gitconfig.GlobalScope} {
+		if cfg, err := gitconfig.LoadConfig(scope); err == nil {
+			configs = append(configs, cfg)
+		}
+	}
+
+	if gitDir != "" {
+		if file, err := os.Open(filepath.Join(gitDir, "config")); err == nil {
+			defer file.Close()
+			if cfg, err := gitconfig.ReadConfig(file); err == nil {
+				configs = append(configs, cfg)
+			}
+		}
+	}
+
+	return configs
+}
+
+// ShouldInclude returns true if the file should be included.
+func (f *GitignoreFilter) ShouldInclude(path string, isDir bool) bool {
+	// Get relative path from worktree root
+	relPath, err := filepath.Rel(f.repoRoot, path)
 	if err != nil {
 		// If we can't get relative path, include it
 		return true
@@ -72,15 +265,31 @@ func (f *GitignoreFilter) ShouldInclude(path string, isDir bool) bool {
 
 	// Convert to forward slashes for gitignore matching
 	relPath = filepath.ToSlash(relPath)
+	if relPath == "." || strings.HasPrefix(relPath, "../") {
+		return true
+	}
 
 	// Split path into components for gitignore matching
 	parts := strings.Split(relPath, "/")
 
-	// Check if any part of the path is ignored
-	matched := f.matcher.Match(parts, isDir)
+	// Load .gitignore files of every ancestor directory (parents first, so
+	// deeper files get higher priority). Unreadable nested files are skipped
+	// here; the read error surfaces when the file itself is output.
+	for i := 1; i < len(parts); i++ {
+		_ = f.loadDir(parts[:i])
+	}
+
+	// Later patterns take precedence, so check from the end
+	for i := len(f.patterns) - 1; i >= 0; i-- {
+		switch f.patterns[i].Match(parts, isDir) {
+		case gitignore.Exclude:
+			return false
+		case gitignore.Include:
+			return true
+		}
+	}
 
-	// If matched, it means it should be ignored
-	return !matched
+	return true
 }
 
 // PatternFilter filters files based on glob patterns.
@@ -61,6 +61,264 @@ node_modules/
 	}
 }
 
+func TestGitignoreFilter_NestedGitignore(t *testing.T) {
+	isolateGitConfig(t)
+	tmpDir := t.TempDir()
+
+	// Root .gitignore ignores logs, nested ones add and override rules
+	files := map[string]string{
+		".gitignore":                 "*.log\n",
+		"services/api/.gitignore":    "build/\n/local.txt\n!keep.log\n",
+		"web/.gitignore":             "*.tmp\n",
+		"services/api/sub/local.txt": "",
+	}
+	writeTestFiles(t, tmpDir, files)
+
+	filter, err := NewGitignoreFilter(tmpDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"root log", "app.log", false, false},
+		{"nested log", "services/api/app.log", false, false},
+		{"negated in nested", "services/api/keep.log", false, true},
+		{"negation scoped to dir", "web/keep.log", false, false},
+		{"nested build dir", "services/api/build", true, false},
+		{"build dir elsewhere", "build", true, true},
+		{"anchored to nested dir", "services/api/local.txt", false, false},
+		{"anchored not in subdir", "services/api/sub/local.txt", false, true},
+		{"web tmp", "web/cache.tmp", false, false},
+		{"tmp outside web", "services/cache.tmp", false, true},
+		{"normal file", "web/index.html", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, filepath.FromSlash(tt.path))
+			got := filter.ShouldInclude(path, tt.isDir)
+			if got != tt.expected {
+				t.Errorf("ShouldInclude(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+// writeTestFiles creates files (slash-separated paths relative to dir) with their contents.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+// isolateGitConfig points HOME and XDG_CONFIG_HOME to an empty directory
+// so that the user's global git configuration doesn't affect the test.
+func isolateGitConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	return home
+}
+
+func TestGitignoreFilter_InfoExclude(t *testing.T) {
+	isolateGitConfig(t)
+	tmpDir := t.TempDir()
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		".git/info/exclude": "*.swp\nscratch/\n",
+		".gitignore":        "!important.swp\n",
+	})
+
+	filter, err := NewGitignoreFilter(tmpDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"swap file", "main.go.swp", false, false},
+		{"scratch dir", "scratch", true, false},
+		{".gitignore overrides exclude", "important.swp", false, true},
+		{"normal file", "main.go", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filter.ShouldInclude(filepath.Join(tmpDir, tt.path), tt.isDir)
+			if got != tt.expected {
+				t.Errorf("ShouldInclude(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGitignoreFilter_GlobalIgnore(t *testing.T) {
+	home := isolateGitConfig(t)
+	tmpDir := t.TempDir()
+
+	// Default global ignore file location ($XDG_CONFIG_HOME/git/ignore)
+	writeTestFiles(t, home, map[string]string{
+		".config/git/ignore": ".idea/\n",
+	})
+
+	filter, err := NewGitignoreFilter(tmpDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+
+	if filter.ShouldInclude(filepath.Join(tmpDir, ".idea"), true) {
+		t.Error("Expected .idea to be excluded by global ignore file")
+	}
+	if !filter.ShouldInclude(filepath.Join(tmpDir, "main.go"), false) {
+		t.Error("Expected main.go to be included")
+	}
+}
+
+func TestGitignoreFilter_CoreExcludesFile(t *testing.T) {
+	home := isolateGitConfig(t)
+	tmpDir := t.TempDir()
+
+	// core.excludesFile in the user config replaces the default global ignore file,
+	// and the repository config overrides the user config
+	writeTestFiles(t, home, map[string]string{
+		".gitconfig":         "[core]\n\texcludesFile = ~/user-ignore\n",
+		"user-ignore":        "*.user\n",
+		"repo-ignore":        "*.repo\n",
+		".config/git/ignore": "*.default\n",
+	})
+
+	filter, err := NewGitignoreFilter(tmpDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+	if filter.ShouldInclude(filepath.Join(tmpDir, "a.user"), false) {
+		t.Error("Expected a.user to be excluded by user excludesFile")
+	}
+	if !filter.ShouldInclude(filepath.Join(tmpDir, "a.default"), false) {
+		t.Error("Expected default global ignore file not to be used when excludesFile is set")
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		".git/config": "[core]\n\texcludesfile = " + filepath.ToSlash(filepath.Join(home, "repo-ignore")) + "\n",
+	})
+
+	filter, err = NewGitignoreFilter(tmpDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+	if !filter.ShouldInclude(filepath.Join(tmpDir, "a.user"), false) {
+		t.Error("Expected user excludesFile to be overridden by repository config")
+	}
+	if filter.ShouldInclude(filepath.Join(tmpDir, "a.repo"), false) {
+		t.Error("Expected a.repo to be excluded by repository excludesFile")
+	}
+}
+
+func TestGitignoreFilter_SubdirectoryOfRepository(t *testing.T) {
+	isolateGitConfig(t)
+	repoDir := t.TempDir()
+
+	writeTestFiles(t, repoDir, map[string]string{
+		".git/info/exclude":       "*.bak\n",
+		".gitignore":              "*.pb.go\n/tmp/\n",
+		"internal/.gitignore":     "/generated/\n",
+		"internal/api/.gitignore": "*.out\n",
+	})
+
+	// Scan only the internal directory
+	rootDir := filepath.Join(repoDir, "internal")
+	filter, err := NewGitignoreFilter(rootDir)
+	if err != nil {
+		t.Fatalf("NewGitignoreFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"root pattern", "api/service.pb.go", false, false},
+		{"anchored root pattern not matching subdir", "tmp", true, true},
+		{"anchored pattern of scanned dir", "generated", true, false},
+		{"nested pattern", "api/run.out", false, false},
+		{"info exclude", "api/service.go.bak", false, false},
+		{"normal file", "api/service.go", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filter.ShouldInclude(filepath.Join(rootDir, filepath.FromSlash(tt.path)), tt.isDir)
+			if got != tt.expected {
+				t.Errorf("ShouldInclude(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindRepositoryRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Main repository and a linked worktree pointing to it
+	writeTestFiles(t, tmpDir, map[string]string{
+		"repo/.git/HEAD":                   "ref: refs/heads/main\n",
+		"repo/.git/worktrees/wt/commondir": "../..\n",
+		"repo/src/main.go":                 "",
+		"wt/.git":                          "gitdir: ../repo/.git/worktrees/wt\n",
+		"wt/src/main.go":                   "",
+		"plain/file.txt":                   "",
+	})
+
+	tests := []struct {
+		name         string
+		dir          string
+		expectedRoot string
+		expectedGit  string
+	}{
+		{"repository root", "repo", "repo", "repo/.git"},
+		{"subdirectory", "repo/src", "repo", "repo/.git"},
+		{"linked worktree", "wt/src", "wt", "repo/.git"},
+		{"not inside a repository", "plain", "", ""},
+	}
+
+	// Resolves an expected path relative to tmpDir, keeping empty as empty
+	resolve := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return filepath.Join(tmpDir, filepath.FromSlash(path))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, gitDir := FindRepositoryRoot(filepath.Join(tmpDir, filepath.FromSlash(tt.dir)))
+			if root != resolve(tt.expectedRoot) {
+				t.Errorf("root = %q, want %q", root, tt.expectedRoot)
+			}
+			if gitDir != resolve(tt.expectedGit) {
+				t.Errorf("gitDir = %q, want %q", gitDir, tt.expectedGit)
+			}
+		})
+	}
+}
+
 func TestPatternFilter_Exclude(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -233,4 +491,4 @@ func TestCompositeFilter_MultipleFilters(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,154 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// jsonNode is the JSON representation of a tree.Node.
+type jsonNode struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	Type       string      `json:"type"` // "directory" or "file"
+	Truncated  bool        `json:"truncated,omitempty"`
+	LinkTarget string      `json:"link_target,omitempty"` // Target of a followed symbolic link
+	Error      string      `json:"error,omitempty"`       // Why the file or directory could not be read
+	Children   []*jsonNode `json:"children,omitempty"`
+}
+
+// jsonFile is the JSON representation of a file.
+type jsonFile struct {
+	Path       string  `json:"path"`
+	Size       int64   `json:"size"`
+	Encoding   string  `json:"encoding,omitempty"`
+	Binary     bool    `json:"binary,omitempty"`
+	Error      string  `json:"error,omitempty"` // Why the file could not be read (content is null)
+	MIMEType   string  `json:"mime_type,omitempty"`
+	LineCount  int     `json:"line_count"`
+	Truncated  bool    `json:"truncated,omitempty"`
+	FirstLine  int     `json:"first_line,omitempty"`  // Set when the file is split across parts
+	LastLine   int     `json:"last_line,omitempty"`   // Set when the file is split across parts
+	TotalLines int     `json:"total_lines,omitempty"` // Set when the file is split across parts
+	Content    *string `json:"content"`               // null for binary files
+}
+
+// jsonDocument is the top-level JSON document.
+type jsonDocument struct {
+	Part  int         `json:"part,omitempty"`  // Set when the output is split into parts
+	Parts int         `json:"parts,omitempty"` // Set when the output is split into parts
+	Tree  *jsonNode   `json:"tree"`
+	Files []*jsonFile `json:"files"`
+}
+
+// jsonRenderer writes a single JSON document with a nested tree and a files array.
+// Files are collected and written when finished.
+type jsonRenderer struct {
+	writer   io.Writer
+	document jsonDocument
+}
+
+func (r *jsonRenderer) writePart(part, parts int) error {
+	r.document.Part = part
+	r.document.Parts = parts
+	return nil
+}
+
+func (r *jsonRenderer) writeTree(root *tree.Node, rendered string) error {
+	r.document.Tree = newJSONNode(root)
+	r.document.Files = []*jsonFile{}
+	return nil
+}
+
+func (r *jsonRenderer) writeFile(file *fileData) error {
+	r.document.Files = append(r.document.Files, newJSONFile(file))
+	return nil
+}
+
+func (r *jsonRenderer) finish() error {
+	encoder := newJSONEncoder(r.writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.document)
+}
+
+// jsonlRenderer writes one JSON object per file, one per line (JSON Lines).
+// The tree is not written since it can be reconstructed from the paths.
+type jsonlRenderer struct {
+	encoder *json.Encoder
+}
+
+func (r *jsonlRenderer) writePart(part, parts int) error {
+	return nil
+}
+
+func (r *jsonlRenderer) writeTree(root *tree.Node, rendered string) error {
+	return nil
+}
+
+func (r *jsonlRenderer) writeFile(file *fileData) error {
+	return r.encoder.Encode(newJSONFile(file))
+}
+
+func (r *jsonlRenderer) finish() error {
+	return nil
+}
+
+// newJSONEncoder creates an encoder that leaves <, > and & unescaped for readability.
+func newJSONEncoder(writer io.Writer) *json.Encoder {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return encoder
+}
+
+// newJSONNode converts a tree node (and its children) to its JSON representation.
+func newJSONNode(node *tree.Node) *jsonNode {
+	result := &jsonNode{
+		Name:       node.Name,
+		Path:       node.Path,
+		Type:       "file",
+		Truncated:  node.Truncated,
+		LinkTarget: node.LinkTarget,
+		Error:      node.Error,
+	}
+	if node.IsDir {
+		result.Type = "directory"
+		for _, child := range node.Children {
+			result.Children = append(result.Children, newJSONNode(child))
+		}
+	}
+	return result
+}
+
+// newJSONFile converts file data to its JSON representation.
+func newJSONFile(file *fileData) *jsonFile {
+	result := &jsonFile{
+		Path:       file.RelPath,
+		Size:       file.Size,
+		Encoding:   file.Encoding,
+		Binary:     file.Binary,
+		Error:      file.Error,
+		MIMEType:   file.MIMEType,
+		Truncated:  file.Truncated,
+		FirstLine:  file.FirstLine,
+		LastLine:   file.LastLine,
+		TotalLines: file.TotalLines,
+	}
+	if !file.Binary && file.Error == "" {
+		content := string(file.Content)
+		result.Content = &content
+		result.LineCount = countLines(file.Content)
+	}
+	return result
+}
+
+// countLines returns the number of lines in content.
+// A final line without a trailing newline is counted as well.
+func countLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,199 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// createJSONTestFiles creates a UTF-8 file, a Shift_JIS file and a binary file.
+func createJSONTestFiles(t *testing.T) (*tree.Node, []scanner.FileEntry) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	goFile := filepath.Join(tmpDir, "src", "main.go")
+	if err := os.WriteFile(goFile, []byte("package main\r\n\r\nfunc main() {}"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+
+	sjisContent, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("日本語\n"))
+	if err != nil {
+		t.Fatalf("Failed to encode Shift_JIS: %v", err)
+	}
+	txtFile := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(txtFile, sjisContent, 0644); err != nil {
+		t.Fatalf("Failed to create txt file: %v", err)
+	}
+
+	binFile := filepath.Join(tmpDir, "data.bin")
+	if err := os.WriteFile(binFile, []byte{0x00, 0x01, 0x02}, 0644); err != nil {
+		t.Fatalf("Failed to create binary file: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: binFile, RelPath: "data.bin", IsDir: false},
+		{Path: txtFile, RelPath: "notes.txt", IsDir: false},
+		{Path: filepath.Join(tmpDir, "src"), RelPath: "src", IsDir: true},
+		{Path: goFile, RelPath: filepath.Join("src", "main.go"), IsDir: false},
+	}
+
+	return tree.Build(entries, "project"), entries
+}
+
+func newSJISEncodingMap(t *testing.T) map[string]encoding.Converter {
+	t.Helper()
+	encodingMap, err := encoding.ParseEncodingMap("txt:shift_jis")
+	if err != nil {
+		t.Fatalf("Failed to parse encoding map: %v", err)
+	}
+	return encodingMap
+}
+
+func TestFormatter_JSON(t *testing.T) {
+	root, entries := createJSONTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{
+		Format:      FormatJSON,
+		EncodingMap: newSJISEncodingMap(t),
+	})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `{
+  "tree": {
+    "name": "project",
+    "path": "",
+    "type": "directory",
+    "children": [
+      {
+        "name": "src",
+        "path": "src",
+        "type": "directory",
+        "children": [
+          {
+            "name": "main.go",
+            "path": "src/main.go",
+            "type": "file"
+          }
+        ]
+      },
+      {
+        "name": "data.bin",
+        "path": "data.bin",
+        "type": "file"
+      },
+      {
+        "name": "notes.txt",
+        "path": "notes.txt",
+        "type": "file"
+      }
+    ]
+  },
+  "files": [
+    {
+      "path": "data.bin",
+      "size": 3,
+      "binary": true,
+      "mime_type": "application/octet-stream",
+      "line_count": 0,
+      "content": null
+    },
+    {
+      "path": "notes.txt",
+      "size": 7,
+      "encoding": "shift_jis",
+      "line_count": 1,
+      "content": "日本語\n"
+    },
+    {
+      "path": "src/main.go",
+      "size": 30,
+      "encoding": "utf-8",
+      "line_count": 3,
+      "content": "package main\n\nfunc main() {}"
+    }
+  ]
+}
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_JSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Format: FormatJSON})
+
+	root := &tree.Node{Name: "", IsDir: true}
+	if err := formatter.Format(root, []scanner.FileEntry{}); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `{
+  "tree": {
+    "name": "",
+    "path": "",
+    "type": "directory"
+  },
+  "files": []
+}
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_JSONL(t *testing.T) {
+	root, entries := createJSONTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{
+		Format:      FormatJSONL,
+		EncodingMap: newSJISEncodingMap(t),
+	})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `{"path":"data.bin","size":3,"binary":true,"mime_type":"application/octet-stream","line_count":0,"content":null}
+{"path":"notes.txt","size":7,"encoding":"shift_jis","line_count":1,"content":"日本語\n"}
+{"path":"src/main.go","size":30,"encoding":"utf-8","line_count":3,"content":"package main\n\nfunc main() {}"}
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"one", 1},
+		{"one\n", 1},
+		{"one\ntwo", 2},
+		{"\n\n", 2},
+	}
+
+	for _, tt := range tests {
+		if got := countLines([]byte(tt.content)); got != tt.expected {
+			t.Errorf("countLines(%q) = %d, want %d", tt.content, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"path"
+	"strings"
+)
+
+// languagesByFilename maps well-known file names to language identifiers.
+var languagesByFilename = map[string]string{
+	"dockerfile":     "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"cmakelists.txt": "cmake",
+	"gemfile":        "ruby",
+	"rakefile":       "ruby",
+	"vagrantfile":    "ruby",
+	"jenkinsfile":    "groovy",
+	".gitignore":     "gitignore",
+	".dockerignore":  "gitignore",
+	".bashrc":        "bash",
+	".zshrc":         "zsh",
+	".editorconfig":  "ini",
+}
+
+// languagesByExtension maps file extensions (without dot, lowercase) to language identifiers
+// used in Markdown code fences.
+var languagesByExtension = map[string]string{
+	"go":         "go",
+	"c":          "c",
+	"h":          "c",
+	"cc":         "cpp",
+	"cpp":        "cpp",
+	"cxx":        "cpp",
+	"hpp":        "cpp",
+	"cs":         "csharp",
+	"java":       "java",
+	"kt":         "kotlin",
+	"kts":        "kotlin",
+	"scala":      "scala",
+	"groovy":     "groovy",
+	"gradle":     "groovy",
+	"rs":         "rust",
+	"swift":      "swift",
+	"m":          "objectivec",
+	"py":         "python",
+	"rb":         "ruby",
+	"php":        "php",
+	"pl":         "perl",
+	"lua":        "lua",
+	"r":          "r",
+	"dart":       "dart",
+	"ex":         "elixir",
+	"exs":        "elixir",
+	"erl":        "erlang",
+	"hs":         "haskell",
+	"clj":        "clojure",
+	"js":         "javascript",
+	"mjs":        "javascript",
+	"cjs":        "javascript",
+	"jsx":        "jsx",
+	"ts":         "typescript",
+	"tsx":        "tsx",
+	"vue":        "vue",
+	"svelte":     "svelte",
+	"html":       "html",
+	"htm":        "html",
+	"css":        "css",
+	"scss":       "scss",
+	"sass":       "sass",
+	"less":       "less",
+	"json":       "json",
+	"jsonc":      "jsonc",
+	"xml":        "xml",
+	"svg":        "xml",
+	"yaml":       "yaml",
+	"yml":        "yaml",
+	"toml":       "toml",
+	"ini":        "ini",
+	"cfg":        "ini",
+	"properties": "properties",
+	"md":         "markdown",
+	"markdown":   "markdown",
+	"rst":        "rst",
+	"tex":        "latex",
+	"sql":        "sql",
+	"graphql":    "graphql",
+	"proto":      "protobuf",
+	"sh":         "bash",
+	"bash":       "bash",
+	"zsh":        "zsh",
+	"fish":       "fish",
+	"ps1":        "powershell",
+	"psm1":       "powershell",
+	"bat":        "batch",
+	"cmd":        "batch",
+	"tf":         "hcl",
+	"hcl":        "hcl",
+	"dockerfile": "dockerfile",
+	"mk":         "makefile",
+	"diff":       "diff",
+	"patch":      "diff",
+	"csv":        "csv",
+	"txt":        "text",
+	"log":        "text",
+}
+
+// languageFor returns the language identifier for the file path
+// derived from its file name or extension. Returns an empty string if unknown.
+func languageFor(relPath string) string {
+	name := strings.ToLower(path.Base(relPath))
+	if lang, ok := languagesByFilename[name]; ok {
+		return lang
+	}
+
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	return languagesByExtension[ext]
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// markdownRenderer writes the tree in a code block and each file as a
+// "## path" heading followed by a fenced code block.
+type markdownRenderer struct {
+	writer io.Writer
+}
+
+func (r *markdownRenderer) writePart(part, parts int) error {
+	_, err := fmt.Fprintf(r.writer, "<!-- part %d of %d -->\n\n", part, parts)
+	return err
+}
+
+func (r *markdownRenderer) writeTree(root *tree.Node, rendered string) error {
+	fence := codeFence([]byte(rendered))
+	_, err := fmt.Fprintf(r.writer, "%s\n%s%s\n", fence, rendered, fence)
+	return err
+}
+
+func (r *markdownRenderer) writeFile(file *fileData) error {
+	if _, err := fmt.Fprintf(r.writer, "\n## %s\n\n", file.displayPath()); err != nil {
+		return err
+	}
+
+	if file.Placeholder != "" {
+		_, err := io.WriteString(r.writer, file.Placeholder+"\n")
+		return err
+	}
+
+	// Use a fence longer than any backtick run so that content can't close it
+	var fence string
+	var missingNewline bool
+	if file.stream != nil {
+		info, err := inspectStream(file)
+		if err != nil {
+			return err
+		}
+		fence = fenceFor(info.backticks)
+		missingNewline = info.size > 0 && info.last != '\n'
+	} else {
+		fence = codeFence(file.Content)
+		missingNewline = len(file.Content) > 0 && file.Content[len(file.Content)-1] != '\n'
+	}
+
+	if _, err := io.WriteString(r.writer, fence+languageFor(file.RelPath)+"\n"); err != nil {
+		return err
+	}
+	if file.stream != nil {
+		if err := copyStream(r.writer, file); err != nil {
+			return err
+		}
+	} else if _, err := r.writer.Write(file.Content); err != nil {
+		return err
+	}
+	if missingNewline {
+		if _, err := io.WriteString(r.writer, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(r.writer, fence+"\n")
+	return err
+}
+
+func (r *markdownRenderer) finish() error {
+	return nil
+}
+
+// codeFence returns a backtick fence longer than the longest backtick run
+// in content (at least three backticks).
+func codeFence(content []byte) string {
+	longest := 0
+	run := 0
+	for _, b := range content {
+		if b == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+
+	return fenceFor(longest)
+}
+
+// fenceFor returns a backtick fence longer than a backtick run of the given
+// length (at least three backticks).
+func fenceFor(longest int) string {
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+func TestFormatter_Markdown(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(goFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "docs"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	mdFile := filepath.Join(tmpDir, "docs", "README.md")
+	if err := os.WriteFile(mdFile, []byte("# Title\n\n```go\nfmt.Println()\n```"), 0644); err != nil {
+		t.Fatalf("Failed to create md file: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: filepath.Join(tmpDir, "docs"), RelPath: "docs", IsDir: true},
+		{Path: mdFile, RelPath: filepath.Join("docs", "README.md"), IsDir: false},
+		{Path: goFile, RelPath: "main.go", IsDir: false},
+	}
+	root := tree.Build(entries, "project")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Format: FormatMarkdown})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// The fence for README.md is longer than the backtick run inside it,
+	// and a newline is added before the closing fence
+	expected := "```\n" +
+		"project\n" +
+		"├── docs/\n" +
+		"│   └── README.md\n" +
+		"└── main.go\n" +
+		"```\n" +
+		"\n" +
+		"## docs/README.md\n" +
+		"\n" +
+		"````markdown\n" +
+		"# Title\n" +
+		"\n" +
+		"```go\n" +
+		"fmt.Println()\n" +
+		"```\n" +
+		"````\n" +
+		"\n" +
+		"## main.go\n" +
+		"\n" +
+		"```go\n" +
+		"package main\n" +
+		"```\n"
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_MarkdownBinaryPlaceholder(t *testing.T) {
+	root, entries := createBinaryTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Format: FormatMarkdown})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := "```\n" +
+		"├── logo.png\n" +
+		"└── main.go\n" +
+		"```\n" +
+		"\n" +
+		"## logo.png\n" +
+		"\n" +
+		"[binary file, 2.0 KiB, image/png]\n" +
+		"\n" +
+		"## main.go\n" +
+		"\n" +
+		"```go\n" +
+		"package main\n" +
+		"```\n"
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"", "```"},
+		{"no backticks", "```"},
+		{"inline `code`", "```"},
+		{"```go\n```", "````"},
+		{"`````", "``````"},
+	}
+
+	for _, tt := range tests {
+		if got := codeFence([]byte(tt.content)); got != tt.expected {
+			t.Errorf("codeFence(%q) = %q, want %q", tt.content, got, tt.expected)
+		}
+	}
+}
+
+func TestLanguageFor(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"main.go", "go"},
+		{"src/App.TSX", "tsx"},
+		{"scripts/build.sh", "bash"},
+		{"Dockerfile", "dockerfile"},
+		{"sub/Makefile", "makefile"},
+		{"config.yml", "yaml"},
+		{"LICENSE", ""},
+		{"data.unknown", ""},
+	}
+
+	for _, tt := range tests {
+		if got := languageFor(tt.path); got != tt.expected {
+			t.Errorf("languageFor(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
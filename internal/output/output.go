@@ -1,21 +1,190 @@
 package output
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/template"
 
 	"github.com/onozaty/treecat/internal/encoding"
 	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/sniff"
+	"github.com/onozaty/treecat/internal/tokenizer"
 	"github.com/onozaty/treecat/internal/tree"
 )
 
+// BinaryMode specifies how binary files are handled.
+type BinaryMode int
+
+const (
+	// BinaryPlaceholder outputs a placeholder instead of the content.
+	BinaryPlaceholder BinaryMode = iota
+	// BinarySkip excludes binary files from both the tree and the contents.
+	BinarySkip
+	// BinaryInclude outputs binary content as-is.
+	BinaryInclude
+)
+
+// ParseBinaryMode parses a binary mode name (placeholder, skip, include).
+func ParseBinaryMode(name string) (BinaryMode, error) {
+	switch strings.ToLower(name) {
+	case "", "placeholder":
+		return BinaryPlaceholder, nil
+	case "skip":
+		return BinarySkip, nil
+	case "include":
+		return BinaryInclude, nil
+	}
+	return BinaryPlaceholder, fmt.Errorf("invalid binary mode: %s (expected placeholder, skip or include)", name)
+}
+
+// Format specifies the output format.
+type Format int
+
+const (
+	// FormatPlain separates files with "=== path ===" markers.
+	FormatPlain Format = iota
+	// FormatMarkdown renders files as headings with fenced code blocks.
+	FormatMarkdown
+	// FormatXML renders files as <document> elements for Claude-style prompts.
+	FormatXML
+	// FormatJSON renders a single JSON document with the tree and all files.
+	FormatJSON
+	// FormatJSONL renders one JSON object per file, one per line.
+	FormatJSONL
+)
+
+// ParseFormat parses an output format name (plain, markdown, xml, json, jsonl).
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "plain":
+		return FormatPlain, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "xml":
+		return FormatXML, nil
+	case "json":
+		return FormatJSON, nil
+	case "jsonl":
+		return FormatJSONL, nil
+	}
+	return FormatPlain, fmt.Errorf("invalid output format: %s (expected plain, markdown, xml, json or jsonl)", name)
+}
+
+// Options holds optional settings for Formatter.
+type Options struct {
+	EncodingMap    map[string]encoding.Converter // extension to converter map (used when EncodingRules is nil)
+	EncodingRules  *encoding.Rules               // encodings by path pattern, first match wins
+	DetectEncoding bool                          // detect the encoding of files without a converter
+	InvalidBytes   encoding.InvalidBytes         // how byte sequences invalid in the encoding are handled
+	OutputEncoding encoding.Converter            // encoding of the output (nil for UTF-8)
+	EOL            encoding.EOL                  // line endings of the output
+	BinaryMode     BinaryMode                    // how binary files are handled
+	Format         Format                        // output format
+	Template       *template.Template            // user-defined template (overrides Format)
+	Tokenizer      tokenizer.Tokenizer           // counts tokens per file (nil to disable)
+	MaxTokens      int                           // token budget for the output (0 for unlimited, requires Tokenizer)
+	TreeStats      bool                          // annotate the tree with sizes, line counts and tokens
+	MaxFileLines   int                           // lines kept per file, head and tail (0 for unlimited)
+	MaxFileBytes   int64                         // bytes kept per file, head and tail (0 for unlimited)
+	SplitSize      int64                         // maximum bytes per part for FormatSplit
+	SplitTokens    int                           // maximum tokens per part for FormatSplit (requires Tokenizer)
+	KeepGoing      bool                          // replace unreadable files with a placeholder instead of failing
+	Jobs           int                           // files read concurrently (0 or 1 reads them one at a time)
+	MaxMemory      int64                         // bytes of files read ahead of the output with Jobs (0 for unlimited)
+}
+
+// FileStats holds statistics of a file processed by Format.
+type FileStats struct {
+	RelPath          string // Relative path with forward slashes
+	Encoding         string // Encoding the content was decoded from (empty for binary and unreadable files)
+	EncodingDetected bool   // Whether the encoding was detected (from a BOM or with DetectEncoding)
+	InvalidBytes     int64  // Invalid bytes replaced or escaped
+	Skipped          bool   // Whether the content was skipped for invalid bytes (InvalidSkipFile)
+	Tokens           int    // Tokens of the written content (0 if not counted or dropped)
+	Truncated        bool   // Whether the content was truncated to fit the token budget
+	Dropped          bool   // Whether the file was dropped because the token budget was exhausted
+}
+
+// Failure is a file or directory that could not be read, recorded with KeepGoing.
+type Failure struct {
+	RelPath string // Relative path with forward slashes
+	IsDir   bool
+	Err     error
+}
+
+// fileData holds a file prepared for output.
+type fileData struct {
+	RelPath     string // Relative path with forward slashes
+	Size        int64  // Original file size in bytes
+	Encoding    string // Encoding the content was decoded from (empty for binary files)
+	Binary      bool   // Whether the file was detected as binary
+	Error       string // Why the file could not be read (Placeholder is set)
+	MIMEType    string // Detected MIME type (set for binary files)
+	Content     []byte // Converted content (nil if Placeholder or stream is set)
+	Placeholder string // Text replacing the content (e.g., for binary files)
+	Truncated   bool   // Whether the content was truncated (by the per-file limits or the token budget)
+	FirstLine   int    // First line of the content when the file is split across parts (0 otherwise)
+	LastLine    int    // Last line of the content when the file is split across parts
+	TotalLines  int    // Total lines of the file when it is split across parts
+
+	readErr          error                         // Why the file could not be read with KeepGoing (recorded as a failure)
+	stream           func() (*streamReader, error) // Opens the converted content of a large file streamed to the output
+	encodingDetected bool                          // Whether Encoding was detected (from a BOM or with DetectEncoding)
+	invalid          int64                         // Invalid bytes replaced or escaped (counted while writing streamed files)
+	skipped          bool                          // Whether the content was skipped for invalid bytes (InvalidSkipFile)
+	tokens           int                           // Tokens of Content counted for the tree stats
+	tokensCounted    bool                          // Whether tokens is set (cleared when Content is truncated)
+	markerLine       bool                          // Whether Content has a line that looks like a plain marker (plain format only)
+}
+
+// displayPath returns the path shown in file headers,
+// with the line range appended when the file is split across parts.
+func (file *fileData) displayPath() string {
+	if file.TotalLines == 0 {
+		return file.RelPath
+	}
+	return fmt.Sprintf("%s (lines %d-%d of %d)", file.RelPath, file.FirstLine, file.LastLine, file.TotalLines)
+}
+
+// renderer writes the tree and files in a specific output format.
+type renderer interface {
+	writePart(part, parts int) error // Called first when the output is split into parts
+	writeTree(root *tree.Node, rendered string) error
+	writeFile(file *fileData) error
+	finish() error
+}
+
 // Formatter formats and writes the output.
 type Formatter struct {
-	writer      io.Writer
-	converter   encoding.Converter               // DEPRECATED: for backward compat during transition
-	encodingMap map[string]encoding.Converter    // extension to converter map
+	writer         io.Writer
+	converter      encoding.Converter            // DEPRECATED: for backward compat during transition
+	encodingMap    map[string]encoding.Converter // extension to converter map
+	encodingRules  *encoding.Rules
+	detectEncoding bool
+	invalidBytes   encoding.InvalidBytes
+	outputEncoding encoding.Converter
+	eol            encoding.EOL
+	binaryMode     BinaryMode
+	format         Format
+	template       *template.Template
+	tokenizer      tokenizer.Tokenizer
+	maxTokens      int
+	treeStats      bool
+	maxFileLines   int
+	maxFileBytes   int64
+	splitSize      int64
+	splitTokens    int
+	keepGoing      bool
+	jobs           int
+	maxMemory      int64
+	stats          []FileStats
+	failures       []Failure
+	treeTokens     int
 }
 
 // NewFormatter creates a new Formatter.
@@ -42,77 +211,442 @@ func NewFormatterWithEncodingMap(writer io.Writer, encodingMap map[string]encodi
 	}
 }
 
+// NewFormatterWithOptions creates a Formatter with the specified options.
+func NewFormatterWithOptions(writer io.Writer, options Options) *Formatter {
+	return &Formatter{
+		writer:         writer,
+		encodingMap:    options.EncodingMap,
+		encodingRules:  options.EncodingRules,
+		detectEncoding: options.DetectEncoding,
+		invalidBytes:   options.InvalidBytes,
+		outputEncoding: options.OutputEncoding,
+		eol:            options.EOL,
+		binaryMode:     options.BinaryMode,
+		format:         options.Format,
+		template:       options.Template,
+		tokenizer:      options.Tokenizer,
+		maxTokens:      options.MaxTokens,
+		treeStats:      options.TreeStats,
+		maxFileLines:   options.MaxFileLines,
+		maxFileBytes:   options.MaxFileBytes,
+		splitSize:      options.SplitSize,
+		splitTokens:    options.SplitTokens,
+		keepGoing:      options.KeepGoing,
+		jobs:           options.Jobs,
+		maxMemory:      options.MaxMemory,
+	}
+}
+
+// Stats returns statistics of the files processed by the last Format call.
+func (f *Formatter) Stats() []FileStats {
+	return f.stats
+}
+
+// Failures returns the files and directories that could not be scanned or
+// read by the last Format call (with KeepGoing), in path order.
+func (f *Formatter) Failures() []Failure {
+	return f.failures
+}
+
+// TotalTokens returns the number of tokens written by the last Format call
+// (the tree and all file contents). Returns 0 if no tokenizer is set.
+func (f *Formatter) TotalTokens() int {
+	total := f.treeTokens
+	for _, stat := range f.stats {
+		total += stat.Tokens
+	}
+	return total
+}
+
+// rendererFactory returns a function creating renderers for the configured
+// output format. The plain format shares the boundary across all renderers
+// (see chooseBoundary).
+func (f *Formatter) rendererFactory(boundary string) func(io.Writer) renderer {
+	if f.template != nil {
+		return func(w io.Writer) renderer {
+			return &templateRenderer{writer: w, template: f.template}
+		}
+	}
+
+	switch f.format {
+	case FormatMarkdown:
+		return func(w io.Writer) renderer { return &markdownRenderer{writer: w} }
+	case FormatXML:
+		return func(w io.Writer) renderer { return &xmlRenderer{writer: w} }
+	case FormatJSON:
+		return func(w io.Writer) renderer { return &jsonRenderer{writer: w} }
+	case FormatJSONL:
+		return func(w io.Writer) renderer { return &jsonlRenderer{encoder: newJSONEncoder(w)} }
+	default:
+		return func(w io.Writer) renderer { return &plainRenderer{writer: w, boundary: boundary} }
+	}
+}
+
+// prepare drops binary files when skipping them, annotates the tree, and
+// returns the tree, the entries to output and the files if they had to be
+// read to annotate the tree (nil otherwise).
+func (f *Formatter) prepare(treeRoot *tree.Node, entries []scanner.FileEntry) (*tree.Node, []scanner.FileEntry, []*fileData, error) {
+	f.failures = nil
+	if f.keepGoing {
+		entries = f.checkReadable(treeRoot, entries)
+	}
+
+	// Drop binary files and rebuild the tree without them
+	if f.binaryMode == BinarySkip {
+		var err error
+		entries, err = f.excludeBinary(entries)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		treeRoot = tree.Build(entries, treeRoot.Name)
+	}
+
+	var files []*fileData
+	if f.treeStats || f.hasFileLimits() {
+		var err error
+		if files, err = f.annotateTree(treeRoot, entries); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return treeRoot, entries, files, nil
+}
+
 // Format writes the complete output (tree + file contents).
 func (f *Formatter) Format(treeRoot *tree.Node, entries []scanner.FileEntry) error {
+	treeRoot, entries, files, err := f.prepare(treeRoot, entries)
+	if err != nil {
+		return err
+	}
+	// Switch to boundary delimiters if markers would be ambiguous
+	var boundary string
+	if files != nil {
+		boundary, err = f.chooseBoundaryFor(files)
+	} else {
+		boundary, err = f.chooseBoundary(entries)
+	}
+	if err != nil {
+		return err
+	}
+	// Encode the output and its line endings while writing
+	writer := encoding.NewWriter(f.writer, f.outputEncoding, f.eol)
+	r := f.rendererFactory(boundary)(writer)
+
 	// Write tree section
-	treeOutput := tree.Render(treeRoot)
-	if _, err := f.writer.Write([]byte(treeOutput)); err != nil {
+	renderedTree := tree.Render(treeRoot)
+	if err := r.writeTree(treeRoot, renderedTree); err != nil {
 		return fmt.Errorf("failed to write tree output: %w", err)
 	}
 
-	// Add blank line separator between tree and file contents
-	if _, err := f.writer.Write([]byte("\n")); err != nil {
-		return fmt.Errorf("failed to write separator: %w", err)
-	}
+	f.stats = nil
+	budget := f.newTokenBudget(renderedTree)
 
-	// Write file contents section
-	for _, entry := range entries {
-		// Skip directories (only output files)
-		if entry.IsDir {
+	// Write file contents section (directories are skipped)
+	reader := f.openFiles(entries, files)
+	defer reader.close()
+	for {
+		file, err := reader.next()
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			break
+		}
+		if !f.fitFile(file, budget) {
 			continue
 		}
 
+		if err := r.writeFile(file); err != nil {
+			return fmt.Errorf("failed to write file content for %s: %w", file.RelPath, err)
+		}
+		if file.stream != nil {
+			// Invalid bytes of streamed files are counted while they are written
+			f.stats[len(f.stats)-1].InvalidBytes = file.invalid
+		}
+	}
+
+	if err := r.finish(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// encode encodes rendered output with the output encoding and line endings.
+func (f *Formatter) encode(rendered []byte) ([]byte, error) {
+	if f.outputEncoding == nil && f.eol != encoding.EOLCRLF {
+		return rendered, nil
+	}
+
+	var buf bytes.Buffer
+	writer := encoding.NewWriter(&buf, f.outputEncoding, f.eol)
+	if _, err := writer.Write(rendered); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitFile applies the per-file limits and the token budget to a file read
+// for output, and records its statistics. Returns false if the file was dropped.
+func (f *Formatter) fitFile(file *fileData, budget *tokenBudget) bool {
+	f.truncateFile(file)
+
+	stats := budget.fit(file)
+	stats.Encoding = file.Encoding
+	stats.EncodingDetected = file.encodingDetected
+	stats.InvalidBytes = file.invalid
+	stats.Skipped = file.skipped
+	f.stats = append(f.stats, stats)
+	return !stats.Dropped
+}
+
+// readFile reads the file and converts it for output
+// (encoding conversion, BOM removal and newline normalization).
+// Binary files get a placeholder instead of content unless BinaryInclude is set.
+// It is called concurrently by the workers of fileReader, so it must not
+// modify the Formatter.
+func (f *Formatter) readFile(entry scanner.FileEntry) (*fileData, error) {
+	file := &fileData{
 		// Normalize path separators to forward slashes for consistent output across platforms
-		normalizedPath := filepath.ToSlash(entry.RelPath)
+		RelPath: filepath.ToSlash(entry.RelPath),
+	}
+
+	if entry.Err != nil {
+		return unreadableFile(file, entry.Err), nil
+	}
+
+	fail := func(err error) (*fileData, error) {
+		if f.keepGoing {
+			file.readErr = err
+			return unreadableFile(file, err), nil
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", entry.RelPath, err)
+	}
 
-		// Write file separator with relative path
-		separator := fmt.Sprintf("=== %s ===\n", normalizedPath)
-		if _, err := f.writer.Write([]byte(separator)); err != nil {
-			return fmt.Errorf("failed to write file separator: %w", err)
+	// Read the leading bytes for binary detection
+	handle, err := os.Open(entry.Path)
+	if err != nil {
+		return fail(err)
+	}
+	defer handle.Close()
+	info, err := handle.Stat()
+	if err != nil {
+		return fail(err)
+	}
+	sample, err := readLeading(handle)
+	if err != nil {
+		return fail(err)
+	}
+
+	file.Size = info.Size()
+	converter, detected := f.selectConverter(entry, sample)
+
+	// Replace binary content with a placeholder. Included binary content
+	// is output as it is, without handling invalid bytes.
+	invalid := f.invalidBytes
+	if result := sniffSample(sample, converter); result.Binary {
+		if f.binaryMode != BinaryInclude {
+			file.Binary = true
+			file.MIMEType = result.MIMEType
+			file.Placeholder = fmt.Sprintf("[binary file, %s, %s]", tree.FormatSize(file.Size), result.MIMEType)
+			return file, nil
 		}
+		invalid = encoding.InvalidKeep
+	}
 
-		// Read and write file contents
-		content, err := os.ReadFile(entry.Path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", entry.RelPath, err)
+	file.Encoding = "utf-8"
+	if converter != nil {
+		file.Encoding = converter.Name()
+	}
+	file.encodingDetected = detected
+
+	// Invalid bytes can't be skipped or fail once output has been written,
+	// so they are checked before
+	failInvalid := func(err error) (*fileData, error) {
+		var invalidErr *encoding.InvalidBytesError
+		if invalid == encoding.InvalidSkipFile && errors.As(err, &invalidErr) {
+			return skippedFile(file, invalidErr), nil
 		}
+		return fail(err)
+	}
 
-		// Select converter based on extension (for encodingMap) or use single converter
-		var converter encoding.Converter
-		if f.encodingMap != nil {
-			ext := filepath.Ext(entry.Path)
-			if ext != "" {
-				normalizedExt := encoding.NormalizeExtension(ext)
-				converter = f.encodingMap[normalizedExt]
+	// Large files are converted while they are written
+	if f.streams(file.Size) {
+		if invalid == encoding.InvalidError || invalid == encoding.InvalidSkipFile {
+			if err := checkStream(entry.Path, converter, invalid, f.eol); err != nil {
+				return failInvalid(err)
 			}
-		} else if f.converter != nil {
-			converter = f.converter
 		}
+		file.stream = func() (*streamReader, error) {
+			return openStream(entry.Path, converter, invalid, f.eol)
+		}
+		return file, nil
+	}
+
+	// Convert encoding, remove BOM and normalize line endings for all files
+	// (not just converted ones) in bounded buffers
+	content := bytes.NewBuffer(make([]byte, 0, file.Size+bytes.MinRead))
+	reader := encoding.NewReader(io.MultiReader(bytes.NewReader(sample), handle), converter, invalid, f.eol)
+	if _, err := content.ReadFrom(reader); err != nil {
+		return failInvalid(err)
+	}
+	file.Content = content.Bytes()
+	file.invalid = reader.Invalid()
+	if f.usesBoundary() {
+		file.markerLine = hasMarkerLine(file.Content)
+	}
+
+	return file, nil
+}
+
+// converterFor selects the converter for the entry based on the path (for
+// encodingRules), the extension (for encodingMap) or the single converter.
+// Returns nil if no conversion is needed, and whether an encoding was
+// specified for the entry (the default of encodingRules is not).
+func (f *Formatter) converterFor(entry scanner.FileEntry) (encoding.Converter, bool) {
+	if f.encodingRules != nil {
+		return f.encodingRules.Match(filepath.ToSlash(entry.RelPath))
+	}
+	if f.encodingMap != nil {
+		ext := filepath.Ext(entry.Path)
+		if ext != "" {
+			normalizedExt := encoding.NormalizeExtension(ext)
+			converter, ok := f.encodingMap[normalizedExt]
+			return converter, ok
+		}
+		return nil, false
+	}
+	return f.converter, f.converter != nil
+}
+
+// selectConverter selects the converter for the entry like converterFor.
+// A byte order mark (UTF-8, UTF-16 or UTF-32) at the start of the file takes
+// precedence over it. With DetectEncoding, the encoding of a file without a
+// specified encoding is detected from its leading bytes. detected is true if
+// the encoding was identified from the content.
+func (f *Formatter) selectConverter(entry scanner.FileEntry, sample []byte) (converter encoding.Converter, detected bool) {
+	// Detect and DetectBOM only return encodings supported by NewConverter
+	if name := encoding.DetectBOM(sample); name != "" {
+		converter, _ = encoding.NewConverter(name)
+		return converter, true
+	}
+
+	converter, specified := f.converterFor(entry)
+	if specified || !f.detectEncoding {
+		return converter, false
+	}
+
+	converter, _ = encoding.NewConverter(encoding.Detect(sample))
+	return converter, true
+}
+
+// sniffSample detects binary content from the leading bytes of a file.
+// UTF-16 and UTF-32 content is decoded first, since its ASCII characters
+// contain NUL bytes. Invalid UTF-8 is only checked for unconverted files.
+func sniffSample(sample []byte, converter encoding.Converter) sniff.Result {
+	if converter != nil && encoding.IsWide(converter.Name()) {
+		if decoded, err := converter.ConvertToUTF8(sample); err == nil {
+			return sniff.Detect(decoded, false)
+		}
+	}
+	return sniff.Detect(sample, converter == nil)
+}
 
-		// Convert encoding if converter found
-		if converter != nil {
-			content, err = converter.ConvertToUTF8(content)
+// excludeBinary returns the entries without binary files.
+// Only the leading bytes of each file are read for detection.
+func (f *Formatter) excludeBinary(entries []scanner.FileEntry) ([]scanner.FileEntry, error) {
+	var kept []scanner.FileEntry
+	for _, entry := range entries {
+		// Unreadable files are kept to show them in the output
+		if !entry.IsDir && entry.Err == nil {
+			sample, err := readSample(entry.Path)
 			if err != nil {
-				return fmt.Errorf("failed to convert encoding for %s: %w", entry.RelPath, err)
+				return nil, fmt.Errorf("failed to read file %s: %w", entry.RelPath, err)
+			}
+			converter, _ := f.selectConverter(entry, sample)
+			if sniffSample(sample, converter).Binary {
+				continue
 			}
 		}
+		kept = append(kept, entry)
+	}
+	return kept, nil
+}
 
-		// Remove BOM from all files (not just converted ones)
-		content, _ = encoding.RemoveBOM(content)
+// readSample reads the leading bytes of a file used for binary detection.
+func readSample(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readLeading(file)
+}
+
+// readLeading reads the leading bytes used for binary detection. One byte
+// more than sniff.SampleSize is read so that sniff.Detect can tell whether
+// the content continues after the sample.
+func readLeading(r io.Reader) ([]byte, error) {
+	sample := make([]byte, sniff.SampleSize+1)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return sample[:n], nil
+}
 
-		// Normalize line endings for all files (not just converted ones)
-		content = encoding.NormalizeNewlines(content)
+// checkReadable records the entries that the scanner could not access and
+// checks that every file can be opened and read, so unreadable files can be
+// marked in the tree before it is rendered. Returns a copy of the entries
+// with Err set for the files that can't be read.
+func (f *Formatter) checkReadable(treeRoot *tree.Node, entries []scanner.FileEntry) []scanner.FileEntry {
+	checked := make([]scanner.FileEntry, len(entries))
+	copy(checked, entries)
 
-		// Write file content
-		if _, err := f.writer.Write(content); err != nil {
-			return fmt.Errorf("failed to write file content for %s: %w", entry.RelPath, err)
+	for i, entry := range checked {
+		if entry.Err == nil && !entry.IsDir {
+			if _, err := readSample(entry.Path); err != nil {
+				checked[i].Err = err
+				if node := tree.Find(treeRoot, filepath.ToSlash(entry.RelPath)); node != nil {
+					node.Error = scanner.ErrorReason(err)
+				}
+			}
+		}
+		if checked[i].Err != nil {
+			f.addFailure(filepath.ToSlash(entry.RelPath), entry.IsDir, checked[i].Err)
 		}
+	}
+	return checked
+}
 
-		// Add blank line after file content
-		if _, err := f.writer.Write([]byte("\n")); err != nil {
-			return fmt.Errorf("failed to write blank line: %w", err)
+// addFailure records a file or directory that could not be read, once.
+func (f *Formatter) addFailure(relPath string, isDir bool, err error) {
+	for _, failure := range f.failures {
+		if failure.RelPath == relPath {
+			return
 		}
 	}
+	f.failures = append(f.failures, Failure{RelPath: relPath, IsDir: isDir, Err: err})
+}
 
-	return nil
+// unreadableFile sets a placeholder for a file that could not be read.
+func unreadableFile(file *fileData, err error) *fileData {
+	file.Error = scanner.ErrorReason(err)
+	file.Placeholder = fmt.Sprintf("[unreadable file: %s]", file.Error)
+	return file
+}
+
+// skippedFile replaces the content of a file having invalid bytes with a
+// placeholder (InvalidSkipFile).
+func skippedFile(file *fileData, err *encoding.InvalidBytesError) *fileData {
+	file.Error = err.Error()
+	file.Placeholder = fmt.Sprintf("[skipped file: %s]", file.Error)
+	file.skipped = true
+	return file
 }
@@ -2,6 +2,8 @@ package output
 
 import (
 	"bytes"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,7 +12,10 @@ import (
 	"github.com/onozaty/treecat/internal/encoding"
 	"github.com/onozaty/treecat/internal/scanner"
 	"github.com/onozaty/treecat/internal/tree"
+	xencoding "golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
 )
 
 func TestFormatter_EmptyOutput(t *testing.T) {
@@ -712,6 +717,50 @@ func TestFormatter_WithEncodingMap_NoExtension(t *testing.T) {
 	}
 }
 
+func TestFormatter_WithEncodingRules(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	text := "こんにちは"
+	shiftJISBytes, _ := japanese.ShiftJIS.NewEncoder().Bytes([]byte(text))
+	eucJPBytes, _ := japanese.EUCJP.NewEncoder().Bytes([]byte(text))
+
+	files := map[string][]byte{
+		"legacy/old/a.txt": shiftJISBytes,
+		"docs/b.txt":       []byte(text),
+		"c.txt":            eucJPBytes,
+	}
+	var entries []scanner.FileEntry
+	for relPath, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", relPath, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: filepath.FromSlash(relPath)})
+	}
+
+	// Unmatched files use the default encoding
+	rules, err := encoding.ParseRules("legacy/**/*.txt:shift_jis,docs/**:utf-8", "euc-jp")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{EncodingRules: rules})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	result := buf.String()
+	for relPath := range files {
+		if !strings.Contains(result, "=== "+relPath+" ===\n"+text+"\n") {
+			t.Errorf("Expected converted content for %s, got:\n%s", relPath, result)
+		}
+	}
+}
+
 func TestFormatter_BOMRemoval(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -827,3 +876,527 @@ func TestFormatter_BOMAndNewlines(t *testing.T) {
 		t.Error("CR characters should be normalized to LF")
 	}
 }
+
+// createBinaryTestFiles creates a text file and a PNG file, returning the entries and tree.
+func createBinaryTestFiles(t *testing.T) (*tree.Node, []scanner.FileEntry) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	textFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(textFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create text file: %v", err)
+	}
+
+	imageFile := filepath.Join(tmpDir, "logo.png")
+	pngContent := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 2040)...)
+	if err := os.WriteFile(imageFile, pngContent, 0644); err != nil {
+		t.Fatalf("Failed to create image file: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: imageFile, RelPath: "logo.png", IsDir: false},
+		{Path: textFile, RelPath: "main.go", IsDir: false},
+	}
+
+	return tree.Build(entries, ""), entries
+}
+
+func TestFormatter_OutputEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	path := filepath.Join(tmpDir, "日本語.txt")
+	if err := os.WriteFile(path, []byte("こんにちは\r\n世界\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: path, RelPath: "日本語.txt"}}
+
+	converter, err := encoding.NewConverter("shift_jis")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{OutputEncoding: converter, EOL: encoding.EOLCRLF})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// The tree, headers and contents are all encoded
+	expected, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("└── 日本語.txt\r\n\r\n=== 日本語.txt ===\r\nこんにちは\r\n世界\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("Expected %q, got %q", expected, buf.Bytes())
+	}
+}
+
+func TestFormatter_PreserveEOL(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	path := filepath.Join(tmpDir, "mixed.txt")
+	if err := os.WriteFile(path, []byte("\xEF\xBB\xBFcrlf\r\ncr\rlf\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: path, RelPath: "mixed.txt"}}
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{EOL: encoding.EOLPreserve})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Line endings of the content are kept, and the BOM is still removed
+	expected := "└── mixed.txt\n\n=== mixed.txt ===\ncrlf\r\ncr\rlf\n\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFormatter_DetectEncoding(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sjisText := "// 設定ファイルを読み込む\nfunc load() {}\n"
+	shiftJISBytes, _ := japanese.ShiftJIS.NewEncoder().Bytes([]byte(sjisText))
+	eucJPBytes, _ := japanese.EUCJP.NewEncoder().Bytes([]byte(sjisText))
+	utf16Text := "UTF-16 テキスト\r\n"
+	utf16Bytes, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(utf16Text))
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"euc.log", eucJPBytes},
+		{"plain.txt", []byte("plain text\n")},
+		{"sjis.txt", shiftJISBytes},
+		{"utf16.txt", utf16Bytes},
+	}
+
+	var entries []scanner.FileEntry
+	for _, file := range files {
+		path := filepath.Join(tmpDir, file.name)
+		if err := os.WriteFile(path, file.content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", file.name, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: file.name})
+	}
+
+	// The encoding map takes precedence over detection
+	encodingMap, _ := encoding.ParseEncodingMap("log:euc-jp")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{EncodingMap: encodingMap, DetectEncoding: true})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	result := buf.String()
+	if strings.Count(result, sjisText) != 2 {
+		t.Errorf("Expected converted Shift_JIS and EUC-JP content, got:\n%s", result)
+	}
+	if !strings.Contains(result, "=== utf16.txt ===\nUTF-16 テキスト\n") {
+		t.Errorf("Expected converted UTF-16 content without BOM, got:\n%s", result)
+	}
+
+	expected := []FileStats{
+		{RelPath: "euc.log", Encoding: "euc-jp"},
+		{RelPath: "plain.txt", Encoding: "utf-8", EncodingDetected: true},
+		{RelPath: "sjis.txt", Encoding: "shift_jis", EncodingDetected: true},
+		{RelPath: "utf16.txt", Encoding: "utf-16le", EncodingDetected: true},
+	}
+	stats := formatter.Stats()
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d stats, got %d", len(expected), len(stats))
+	}
+	for i := range expected {
+		if stats[i] != expected[i] {
+			t.Errorf("Stats[%d] = %+v, want %+v", i, stats[i], expected[i])
+		}
+	}
+}
+
+func TestFormatter_DetectEncoding_Binary(t *testing.T) {
+	root, entries := createBinaryTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{DetectEncoding: true})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "=== logo.png ===\n[binary file, 2.0 KiB, image/png]\n") {
+		t.Errorf("Expected binary placeholder, got:\n%s", buf.String())
+	}
+	if stats := formatter.Stats(); stats[0].Encoding != "" {
+		t.Errorf("Expected no encoding for binary file, got %q", stats[0].Encoding)
+	}
+}
+
+func TestFormatter_UnicodeBOM(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	text := "Write-Host \"こんにちは\"\r\n"
+	encode := func(enc xencoding.Encoding) []byte {
+		encoded, err := enc.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		return encoded
+	}
+
+	files := []struct {
+		name     string
+		content  []byte
+		encoding string
+		detected bool
+	}{
+		{"mapped.txt", encode(japanese.ShiftJIS), "shift_jis", false},
+		{"utf16be.ps1", encode(unicode.UTF16(unicode.BigEndian, unicode.UseBOM)), "utf-16be", true},
+		{"utf16le.ps1", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)), "utf-16le", true},
+		// The BOM takes precedence over the encoding map
+		{"utf16le.txt", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)), "utf-16le", true},
+		{"utf32le.ps1", encode(utf32.UTF32(utf32.LittleEndian, utf32.UseBOM)), "utf-32le", true},
+		{"utf8bom.txt", append([]byte("\xEF\xBB\xBF"), text...), "utf-8", true},
+	}
+
+	var entries []scanner.FileEntry
+	for _, file := range files {
+		path := filepath.Join(tmpDir, file.name)
+		if err := os.WriteFile(path, file.content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", file.name, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: file.name})
+	}
+
+	encodingMap, _ := encoding.ParseEncodingMap("txt:shift_jis")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{EncodingMap: encodingMap})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	result := buf.String()
+	for i, file := range files {
+		if !strings.Contains(result, "=== "+file.name+" ===\nWrite-Host \"こんにちは\"\n") {
+			t.Errorf("Expected converted content for %s, got:\n%s", file.name, result)
+		}
+
+		stats := formatter.Stats()[i]
+		if stats.Encoding != file.encoding || stats.EncodingDetected != file.detected {
+			t.Errorf("Expected encoding %s (detected: %v) for %s, got %s (detected: %v)",
+				file.encoding, file.detected, file.name, stats.Encoding, stats.EncodingDetected)
+		}
+	}
+	if strings.Contains(result, "\x00") || strings.Contains(result, "\ufeff") {
+		t.Errorf("Expected no NUL bytes or BOM in output, got:\n%q", result)
+	}
+}
+
+func TestFormatter_InvalidBytes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	shiftJISBytes, _ := japanese.ShiftJIS.NewEncoder().Bytes([]byte("日本語"))
+	files := map[string][]byte{
+		"a.txt":   []byte("ok\n"),
+		"b.txt":   []byte("bad \xff\xfe\n"),
+		"sjis.md": append(shiftJISBytes, 0x85, 0x40, '\n'),
+	}
+	var entries []scanner.FileEntry
+	for _, relPath := range []string{"a.txt", "b.txt", "sjis.md"} {
+		path := filepath.Join(tmpDir, relPath)
+		if err := os.WriteFile(path, files[relPath], 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", relPath, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: relPath})
+	}
+
+	rules, err := encoding.ParseRules("md:shift_jis", "")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		policy   encoding.InvalidBytes
+		format   Format
+		expected []string
+		invalid  []int64
+		skipped  []bool
+	}{
+		{
+			name:     "replace",
+			policy:   encoding.InvalidReplace,
+			expected: []string{"=== b.txt ===\nbad \uFFFD\uFFFD\n", "=== sjis.md ===\n日本語\uFFFD\n"},
+			invalid:  []int64{0, 2, 2},
+			skipped:  []bool{false, false, false},
+		},
+		{
+			name:     "escape",
+			policy:   encoding.InvalidEscape,
+			expected: []string{"=== b.txt ===\nbad \\xFF\\xFE\n", "=== sjis.md ===\n日本語\\x85\\x40\n"},
+			invalid:  []int64{0, 2, 2},
+			skipped:  []bool{false, false, false},
+		},
+		{
+			name:   "skip-file",
+			policy: encoding.InvalidSkipFile,
+			format: FormatJSON,
+			expected: []string{
+				`"error": "invalid utf-8 byte sequence at offset 4",`,
+				`"error": "invalid shift_jis byte sequence at offset 6"`,
+			},
+			invalid: []int64{0, 0, 0},
+			skipped: []bool{false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			formatter := NewFormatterWithOptions(&buf, Options{EncodingRules: rules, InvalidBytes: tt.policy, Format: tt.format})
+			if err := formatter.Format(tree.Build(entries, ""), entries); err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			for _, expected := range tt.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("Expected %q in output, got:\n%s", expected, buf.String())
+				}
+			}
+
+			stats := formatter.Stats()
+			if len(stats) != len(entries) {
+				t.Fatalf("Expected %d stats, got %d", len(entries), len(stats))
+			}
+			for i, stat := range stats {
+				if stat.InvalidBytes != tt.invalid[i] || stat.Skipped != tt.skipped[i] {
+					t.Errorf("Unexpected stats for %s: %+v", stat.RelPath, stat)
+				}
+			}
+		})
+	}
+
+	// Fails on the first invalid byte
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{InvalidBytes: encoding.InvalidError})
+	err = formatter.Format(tree.Build(entries, ""), entries)
+	var invalidErr *encoding.InvalidBytesError
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("Expected InvalidBytesError, got %v", err)
+	}
+	if err.Error() != "failed to read file b.txt: invalid utf-8 byte sequence at offset 4" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestFormatter_BinaryPlaceholder(t *testing.T) {
+	root, entries := createBinaryTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `├── logo.png
+└── main.go
+
+=== logo.png ===
+[binary file, 2.0 KiB, image/png]
+
+=== main.go ===
+package main
+
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_BinarySkip(t *testing.T) {
+	root, entries := createBinaryTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{BinaryMode: BinarySkip})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Binary file is removed from the tree as well
+	expected := `└── main.go
+
+=== main.go ===
+package main
+
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_BinaryInclude(t *testing.T) {
+	root, entries := createBinaryTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{BinaryMode: BinaryInclude})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "=== logo.png ===\n\x89PNG") {
+		t.Error("Expected raw binary content in output")
+	}
+	if strings.Contains(buf.String(), "[binary file") {
+		t.Error("Expected no placeholder in output")
+	}
+}
+
+func TestFormatter_LegacyEncodedTextIsNotBinary(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Mostly invalid UTF-8, but text when read as Shift_JIS
+	content, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(strings.Repeat("日本語のテキスト\n", 100)))
+	if err != nil {
+		t.Fatalf("Failed to encode Shift_JIS: %v", err)
+	}
+	filePath := filepath.Join(tmpDir, "sjis.txt")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: filePath, RelPath: "sjis.txt"}}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(tree.Build(entries, ""), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// The content is written with the invalid UTF-8 bytes replaced
+	result := buf.String()
+	if strings.Contains(result, "[binary file") {
+		t.Errorf("Expected no binary placeholder, got:\n%s", result)
+	}
+	if strings.Count(result, "\n") != strings.Count(string(content), "\n")+4 {
+		t.Errorf("Expected every line of the file in output, got:\n%s", result)
+	}
+}
+
+func TestFormatter_KeepGoing(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	textFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(textFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create text file: %v", err)
+	}
+
+	// Scanned, but removed before it is read
+	entries := []scanner.FileEntry{
+		{Path: filepath.Join(tmpDir, "gone.txt"), RelPath: "gone.txt"},
+		{Path: filepath.Join(tmpDir, "locked"), RelPath: "locked", IsDir: true, Err: &fs.PathError{Op: "open", Path: "locked", Err: fs.ErrPermission}},
+		{Path: textFile, RelPath: "main.go"},
+	}
+	root := tree.Build(entries, "")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{KeepGoing: true})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `├── locked/ [error: permission denied]
+├── gone.txt [error: no such file or directory]
+└── main.go
+
+=== gone.txt ===
+[unreadable file: no such file or directory]
+
+=== main.go ===
+package main
+
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+
+	failures := formatter.Failures()
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d: %v", len(failures), failures)
+	}
+	if failures[0].RelPath != "gone.txt" || !errors.Is(failures[0].Err, fs.ErrNotExist) {
+		t.Errorf("Unexpected failure: %+v", failures[0])
+	}
+	if failures[1].RelPath != "locked" || !failures[1].IsDir {
+		t.Errorf("Unexpected failure: %+v", failures[1])
+	}
+}
+
+func TestFormatter_WithoutKeepGoing(t *testing.T) {
+	tmpDir := t.TempDir()
+	entries := []scanner.FileEntry{
+		{Path: filepath.Join(tmpDir, "gone.txt"), RelPath: "gone.txt"},
+	}
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{})
+	if err := formatter.Format(tree.Build(entries, ""), entries); err == nil {
+		t.Error("Expected error for a file that can't be read")
+	}
+}
+
+func TestParseBinaryMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected BinaryMode
+		wantErr  bool
+	}{
+		{"", BinaryPlaceholder, false},
+		{"placeholder", BinaryPlaceholder, false},
+		{"skip", BinarySkip, false},
+		{"Include", BinaryInclude, false},
+		{"invalid", BinaryPlaceholder, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseBinaryMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBinaryMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseBinaryMode(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{"", FormatPlain, false},
+		{"plain", FormatPlain, false},
+		{"markdown", FormatMarkdown, false},
+		{"MD", FormatMarkdown, false},
+		{"xml", FormatXML, false},
+		{"json", FormatJSON, false},
+		{"jsonl", FormatJSONL, false},
+		{"invalid", FormatPlain, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseFormat(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package output
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// BoundaryDeclarationPrefix starts the line declaring the boundary in plain output.
+// The line has the form "=== treecat:boundary=<boundary> ===".
+const BoundaryDeclarationPrefix = "=== treecat:boundary="
+
+// plainRenderer writes files separated by "=== path ===" markers.
+//
+// If any file contains a line that looks like a marker, the markers can't be
+// told apart from content. In that case a random boundary that appears in no
+// file is used instead, similar to MIME multipart:
+//
+//	=== treecat:boundary=<boundary> ===
+//	--<boundary> path
+//	content
+//	--<boundary> path
+//	content
+//	--<boundary>--
+type plainRenderer struct {
+	writer   io.Writer
+	boundary string // Empty when "=== path ===" markers are unambiguous
+}
+
+func (r *plainRenderer) writePart(part, parts int) error {
+	_, err := fmt.Fprintf(r.writer, "--- part %d of %d ---\n\n", part, parts)
+	return err
+}
+
+func (r *plainRenderer) writeTree(root *tree.Node, rendered string) error {
+	// Add blank line separator between tree and file contents
+	if _, err := io.WriteString(r.writer, rendered+"\n"); err != nil {
+		return err
+	}
+
+	if r.boundary != "" {
+		_, err := fmt.Fprintf(r.writer, "%s%s ===\n", BoundaryDeclarationPrefix, r.boundary)
+		return err
+	}
+	return nil
+}
+
+func (r *plainRenderer) writeFile(file *fileData) error {
+	// Write file separator with relative path
+	if r.boundary != "" {
+		if _, err := fmt.Fprintf(r.writer, "--%s %s\n", r.boundary, file.displayPath()); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintf(r.writer, "=== %s ===\n", file.displayPath()); err != nil {
+			return err
+		}
+	}
+
+	if file.Placeholder != "" {
+		_, err := io.WriteString(r.writer, file.Placeholder+"\n\n")
+		return err
+	}
+
+	// Write file content followed by a blank line
+	if file.stream != nil {
+		if err := copyStream(r.writer, file); err != nil {
+			return err
+		}
+	} else if _, err := r.writer.Write(file.Content); err != nil {
+		return err
+	}
+	_, err := io.WriteString(r.writer, "\n")
+	return err
+}
+
+func (r *plainRenderer) finish() error {
+	if r.boundary != "" {
+		_, err := fmt.Fprintf(r.writer, "--%s--\n", r.boundary)
+		return err
+	}
+	return nil
+}
+
+// usesBoundary returns true if the output is in the plain format, whose
+// markers may need a boundary.
+func (f *Formatter) usesBoundary() bool {
+	return f.template == nil && f.format == FormatPlain
+}
+
+// chooseBoundary returns an empty string if no file contains a line that looks
+// like a "=== path ===" marker. Otherwise it returns a random boundary that
+// doesn't appear in any file. Files are checked as they are written, after
+// encoding conversion and newline normalization, by the workers of Jobs.
+// A boundary is generated before the files are read, so that they are read
+// only once unless it happens to appear in a file. Other formats need no
+// boundary.
+func (f *Formatter) chooseBoundary(entries []scanner.FileEntry) (string, error) {
+	if !f.usesBoundary() {
+		return "", nil
+	}
+
+	collision := false
+	var files []scanner.FileEntry
+	for _, entry := range entries {
+		// Unreadable files are written as placeholders
+		if entry.IsDir || entry.Err != nil {
+			continue
+		}
+		collision = collision || isDeclarationLike(entry.RelPath)
+		files = append(files, entry)
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return "", err
+	}
+	marker, contains, err := f.scanFiles(files, boundary)
+	if err != nil {
+		return "", err
+	}
+	if !collision && !marker {
+		return "", nil
+	}
+
+	for contains {
+		if boundary, err = newBoundary(); err != nil {
+			return "", err
+		}
+		if _, contains, err = f.scanFiles(files, boundary); err != nil {
+			return "", err
+		}
+	}
+	return boundary, nil
+}
+
+// chooseBoundaryFor is chooseBoundary for files already read into memory,
+// using the marker lines found by readFile.
+func (f *Formatter) chooseBoundaryFor(files []*fileData) (string, error) {
+	if !f.usesBoundary() {
+		return "", nil
+	}
+
+	collision := false
+	for _, file := range files {
+		collision = collision || file.markerLine || isDeclarationLike(file.RelPath)
+	}
+	if !collision {
+		return "", nil
+	}
+
+	for {
+		boundary, err := newBoundary()
+		if err != nil {
+			return "", err
+		}
+		found := false
+		for _, file := range files {
+			found = found || bytes.Contains(file.Content, []byte(boundary))
+		}
+		if !found {
+			return boundary, nil
+		}
+	}
+}
+
+// isDeclarationLike reports whether the separator of a file with the path
+// would be ambiguous with the boundary declaration.
+func isDeclarationLike(relPath string) bool {
+	return strings.HasPrefix(filepath.ToSlash(relPath), strings.TrimPrefix(BoundaryDeclarationPrefix, "=== "))
+}
+
+// hasMarkerLine reports whether content has a line that looks like a "=== ... ===" marker.
+func hasMarkerLine(content []byte) bool {
+	var scanner markerScanner
+	scanner.Write(content)
+	scanner.endLine()
+	return scanner.found
+}
+
+// scanFiles reads the converted content of the files with the workers of
+// Jobs, and reports whether any of them has a line that looks like a marker
+// and whether any of them contains the boundary.
+func (f *Formatter) scanFiles(files []scanner.FileEntry, boundary string) (marker, contains bool, err error) {
+	type scanResult struct {
+		marker, contains bool
+		err              error
+	}
+	results := make([]scanResult, len(files))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(f.jobs, len(files))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result := &results[i]
+				result.marker, result.contains, result.err = f.scanConverted(files[i], []byte(boundary))
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Report the error of the first file, like reading them in order
+	for i, result := range results {
+		if result.err != nil {
+			return false, false, fmt.Errorf("failed to read file %s: %w", files[i].RelPath, result.err)
+		}
+		marker = marker || result.marker
+		contains = contains || result.contains
+	}
+	return marker, contains, nil
+}
+
+// newBoundary generates a random boundary string.
+func newBoundary() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate boundary: %w", err)
+	}
+	return "treecat-" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,243 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestFormatter_PlainBoundaryOnMarkerCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A file containing a line that looks like a file separator
+	fixture := filepath.Join(tmpDir, "fixture.txt")
+	if err := os.WriteFile(fixture, []byte("before\n=== main.go ===\r\nafter\n"), 0644); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	goFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(goFile, []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: fixture, RelPath: "fixture.txt"},
+		{Path: goFile, RelPath: "main.go"},
+	}
+	root := tree.Build(entries, "")
+
+	var buf bytes.Buffer
+	if err := NewFormatterWithOptions(&buf, Options{}).Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	result := buf.String()
+
+	match := regexp.MustCompile(`(?m)^=== treecat:boundary=(\S+) ===$`).FindStringSubmatch(result)
+	if match == nil {
+		t.Fatalf("Expected boundary declaration, got:\n%s", result)
+	}
+	boundary := match[1]
+
+	expected := `├── fixture.txt
+└── main.go
+
+=== treecat:boundary=` + boundary + ` ===
+--` + boundary + ` fixture.txt
+before
+=== main.go ===
+after
+
+--` + boundary + ` main.go
+package main
+--` + boundary + `--
+`
+
+	if result != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+func TestFormatter_PlainBoundaryIsRandom(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	filePath := filepath.Join(tmpDir, "out.txt")
+	if err := os.WriteFile(filePath, []byte("=== x ===\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: filePath, RelPath: "out.txt"}}
+
+	boundary1, err := NewFormatter(io.Discard).chooseBoundary(entries)
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+	boundary2, err := NewFormatter(io.Discard).chooseBoundary(entries)
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+
+	if boundary1 == "" || boundary2 == "" {
+		t.Fatal("Expected boundary for colliding content")
+	}
+	if boundary1 == boundary2 {
+		t.Errorf("Expected different boundaries per run, got %q twice", boundary1)
+	}
+	if !strings.HasPrefix(boundary1, "treecat-") {
+		t.Errorf("Unexpected boundary format: %q", boundary1)
+	}
+}
+
+func TestChooseBoundary_NoCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	filePath := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(filePath, []byte("a === b ===\n====\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	boundary, err := NewFormatter(io.Discard).chooseBoundary([]scanner.FileEntry{{Path: filePath, RelPath: "a.txt"}})
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+	if boundary != "" {
+		t.Errorf("Expected no boundary, got %q", boundary)
+	}
+}
+
+func TestChooseBoundary_ConvertedContent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	utf16Bytes, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("=== a.txt ===\n"))
+	if err != nil {
+		t.Fatalf("Failed to encode UTF-16: %v", err)
+	}
+
+	// Markers that only appear as lines once the content is decoded and
+	// its line endings are normalized
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"UTF-16LE with BOM", utf16Bytes},
+		{"CR line endings", []byte("x\r=== a.txt ===\ry")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(tmpDir, "file.txt")
+			if err := os.WriteFile(filePath, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+
+			boundary, err := NewFormatter(io.Discard).chooseBoundary([]scanner.FileEntry{{Path: filePath, RelPath: "file.txt"}})
+			if err != nil {
+				t.Fatalf("chooseBoundary failed: %v", err)
+			}
+			if boundary == "" {
+				t.Error("Expected boundary for converted content with a marker line")
+			}
+		})
+	}
+}
+
+func TestChooseBoundary_PathLikeDeclaration(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	filePath := filepath.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// A file whose separator would read as a boundary declaration
+	boundary, err := NewFormatter(io.Discard).chooseBoundary([]scanner.FileEntry{{Path: filePath, RelPath: "treecat:boundary=x"}})
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+	if boundary == "" {
+		t.Error("Expected boundary for path resembling the declaration")
+	}
+}
+
+func TestChooseBoundary_Jobs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var entries []scanner.FileEntry
+	for i := range 20 {
+		filePath := filepath.Join(tmpDir, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(filePath, []byte(strings.Repeat("content\n", i+1)), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: filePath, RelPath: filepath.Base(filePath)})
+	}
+
+	formatter := NewFormatterWithOptions(io.Discard, Options{Jobs: 4})
+	boundary, err := formatter.chooseBoundary(entries)
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+	if boundary != "" {
+		t.Errorf("Expected no boundary, got %q", boundary)
+	}
+
+	// A marker line in the last file is found by the workers
+	if err := os.WriteFile(entries[19].Path, []byte("=== main.go ===\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	boundary, err = formatter.chooseBoundary(entries)
+	if err != nil {
+		t.Fatalf("chooseBoundary failed: %v", err)
+	}
+	if boundary == "" {
+		t.Error("Expected boundary for colliding content")
+	}
+}
+
+func TestFormatter_FormatSplitBoundary(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fixture := filepath.Join(tmpDir, "fixture.txt")
+	if err := os.WriteFile(fixture, []byte("=== main.go ===\n"), 0644); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: fixture, RelPath: "fixture.txt"}}
+
+	// The boundary is chosen from the files read for splitting
+	parts, err := NewFormatterWithOptions(nil, Options{SplitSize: 1000}).FormatSplit(tree.Build(entries, ""), entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+	match := regexp.MustCompile(`(?m)^=== treecat:boundary=(\S+) ===$`).FindSubmatch(parts[0])
+	if match == nil {
+		t.Fatalf("Expected boundary declaration, got:\n%s", parts[0])
+	}
+	if !bytes.Contains(parts[0], []byte("--"+string(match[1])+" fixture.txt\n=== main.go ===\n")) {
+		t.Errorf("Expected file with boundary separator, got:\n%s", parts[0])
+	}
+}
+
+func TestHasMarkerLine(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected bool
+	}{
+		{"", false},
+		{"=== main.go ===", true},
+		{"x\n=== a ===\r\ny", true},
+		{" === a ===", false},
+		{"=== a === x", false},
+		{"======", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasMarkerLine([]byte(tt.content)); got != tt.expected {
+			t.Errorf("hasMarkerLine(%q) = %v, want %v", tt.content, got, tt.expected)
+		}
+	}
+}
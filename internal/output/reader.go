@@ -0,0 +1,230 @@
+package output
+
+import (
+	"sync"
+
+	"github.com/onozaty/treecat/internal/scanner"
+)
+
+// fileSource returns the files to output in entry order.
+type fileSource interface {
+	next() (*fileData, error)
+	close()
+}
+
+// fileReader reads the files of the entries with readFile and returns them in
+// entry order. With more than one job, files are read and decoded by a pool
+// of workers ahead of the consumer, so the output is the same as reading them
+// one at a time.
+type fileReader struct {
+	formatter *Formatter
+	files     []scanner.FileEntry
+	index     int // Next file to return
+
+	// Set when reading with workers
+	results []chan readResult // One per file, filled by the workers
+	memory  *memoryLimit
+	held    int64 // Memory reserved for the file last returned
+	done    chan struct{}
+}
+
+// readResult is a file read by a worker.
+type readResult struct {
+	file     *fileData
+	err      error
+	reserved int64
+}
+
+// readJob is a file to be read by a worker.
+type readJob struct {
+	index    int
+	entry    scanner.FileEntry
+	reserved int64
+}
+
+// newFileReader starts reading the files of the entries (directories are
+// skipped). The reader must be closed to stop the workers.
+func (f *Formatter) newFileReader(entries []scanner.FileEntry) *fileReader {
+	r := &fileReader{formatter: f}
+	for _, entry := range entries {
+		if !entry.IsDir {
+			r.files = append(r.files, entry)
+		}
+	}
+	if f.jobs <= 1 || len(r.files) <= 1 {
+		return r
+	}
+
+	r.results = make([]chan readResult, len(r.files))
+	for i := range r.results {
+		r.results[i] = make(chan readResult, 1)
+	}
+	r.memory = newMemoryLimit(f.maxMemory)
+	r.done = make(chan struct{})
+
+	// Memory is reserved in entry order, so the file the consumer waits for
+	// never waits for memory held by files after it
+	jobs := make(chan readJob)
+	go func() {
+		defer close(jobs)
+		for i, entry := range r.files {
+			size := entry.Size
+			if f.streams(size) {
+				size = 0 // Not held in memory
+			}
+			reserved, ok := r.memory.acquire(size)
+			if !ok {
+				return
+			}
+			select {
+			case jobs <- readJob{index: i, entry: entry, reserved: reserved}:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	for range min(f.jobs, len(r.files)) {
+		go func() {
+			for job := range jobs {
+				file, err := f.readFile(job.entry)
+				r.results[job.index] <- readResult{file: file, err: err, reserved: job.reserved}
+			}
+		}()
+	}
+	return r
+}
+
+// next returns the next file, or nil when all files have been returned.
+// The memory held by the previous file is released, so the consumer must be
+// done with it (files kept for later are not counted against the limit).
+// Files that could not be read (with KeepGoing) are recorded as failures.
+func (r *fileReader) next() (*fileData, error) {
+	if r.index == len(r.files) {
+		r.release()
+		return nil, nil
+	}
+	entry := r.files[r.index]
+	r.index++
+
+	var file *fileData
+	var err error
+	if r.results == nil {
+		file, err = r.formatter.readFile(entry)
+	} else {
+		r.release()
+		result := <-r.results[r.index-1]
+		r.held = result.reserved
+		file, err = result.file, result.err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if file.readErr != nil {
+		r.formatter.addFailure(file.RelPath, false, file.readErr)
+	}
+	return file, nil
+}
+
+// release releases the memory held by the file last returned.
+func (r *fileReader) release() {
+	if r.memory != nil {
+		r.memory.release(r.held)
+		r.held = 0
+	}
+}
+
+// close stops reading files ahead. Files already being read are discarded.
+func (r *fileReader) close() {
+	if r.done == nil {
+		return
+	}
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+		r.memory.close()
+	}
+}
+
+// collectedFiles returns files already read in order, like fileReader.
+type collectedFiles struct {
+	files []*fileData
+	index int
+}
+
+func (c *collectedFiles) next() (*fileData, error) {
+	if c.index == len(c.files) {
+		return nil, nil
+	}
+	c.index++
+	return c.files[c.index-1], nil
+}
+
+func (c *collectedFiles) close() {}
+
+// openFiles returns the files read by prepare, or starts reading the files of
+// the entries if they were not read (files is nil).
+func (f *Formatter) openFiles(entries []scanner.FileEntry, files []*fileData) fileSource {
+	if files != nil {
+		return &collectedFiles{files: files}
+	}
+	return f.newFileReader(entries)
+}
+
+// memoryLimit limits the bytes of files read ahead of the consumer.
+type memoryLimit struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int64 // 0 for unlimited
+	used   int64
+	closed bool
+}
+
+// newMemoryLimit creates a limit of the given bytes (0 for unlimited).
+func newMemoryLimit(limit int64) *memoryLimit {
+	m := &memoryLimit{limit: limit}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// acquire waits until size bytes are available and reserves them, returning
+// the bytes reserved. A file larger than the limit reserves the whole limit,
+// so it is read once nothing else is held. Returns false if the limit was closed.
+func (m *memoryLimit) acquire(size int64) (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.limit <= 0 {
+		return 0, !m.closed
+	}
+	size = min(size, m.limit)
+	for !m.closed && m.used+size > m.limit {
+		m.cond.Wait()
+	}
+	if m.closed {
+		return 0, false
+	}
+	m.used += size
+	return size, true
+}
+
+// release returns reserved bytes to the limit.
+func (m *memoryLimit) release(size int64) {
+	if size == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.used -= size
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// close wakes up and fails all waiting and future acquisitions.
+func (m *memoryLimit) close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
@@ -0,0 +1,193 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tokenizer"
+	"github.com/onozaty/treecat/internal/tree"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// createParallelTestFiles creates files of varying sizes, including
+// Shift_JIS, binary and missing files, returning the entries and tree.
+func createParallelTestFiles(t *testing.T) (*tree.Node, []scanner.FileEntry) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	sjis, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("こんにちは\r\n世界\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to encode Shift_JIS: %v", err)
+	}
+
+	var entries []scanner.FileEntry
+	for i := range 60 {
+		dir := fmt.Sprintf("dir%02d", i/10)
+		if i%10 == 0 {
+			if err := os.Mkdir(filepath.Join(tmpDir, dir), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			entries = append(entries, scanner.FileEntry{Path: filepath.Join(tmpDir, dir), RelPath: dir, IsDir: true})
+		}
+
+		name := fmt.Sprintf("file%02d.txt", i)
+		content := []byte(strings.Repeat(fmt.Sprintf("line %d of %s\n", i, name), (i*37)%200+1))
+		switch i % 7 {
+		case 3:
+			name = fmt.Sprintf("file%02d.sjis", i)
+			content = sjis
+		case 5:
+			name = fmt.Sprintf("file%02d.bin", i)
+			content = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+		}
+
+		relPath := filepath.Join(dir, name)
+		path := filepath.Join(tmpDir, relPath)
+		if i%13 != 12 { // Missing files can't be read
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: relPath, Size: int64(len(content))})
+	}
+
+	return tree.Build(entries, ""), entries
+}
+
+func TestFormatter_JobsMatchSequential(t *testing.T) {
+	converter, err := encoding.NewConverter("shift_jis")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"plain", Options{}},
+		{"json", Options{Format: FormatJSON}},
+		{"tree stats", Options{TreeStats: true, MaxFileLines: 20}},
+		{"token budget", Options{Tokenizer: tokenizer.NewEstimator(), MaxTokens: 3000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := func(jobs int, maxMemory int64) (string, []FileStats, []Failure) {
+				root, entries := createParallelTestFiles(t)
+				options := tt.options
+				options.EncodingMap = map[string]encoding.Converter{"sjis": converter}
+				options.KeepGoing = true
+				options.Jobs = jobs
+				options.MaxMemory = maxMemory
+
+				var buf bytes.Buffer
+				formatter := NewFormatterWithOptions(&buf, options)
+				if err := formatter.Format(root, entries); err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				return buf.String(), formatter.Stats(), formatter.Failures()
+			}
+
+			expected, expectedStats, expectedFailures := format(1, 0)
+			for _, jobs := range []int{2, 8} {
+				for _, maxMemory := range []int64{0, 1, 4096} {
+					result, stats, failures := format(jobs, maxMemory)
+					if result != expected {
+						t.Errorf("Output with %d jobs and %d bytes differs from sequential output", jobs, maxMemory)
+					}
+					if fmt.Sprint(stats) != fmt.Sprint(expectedStats) {
+						t.Errorf("Stats with %d jobs differ: %v, expected %v", jobs, stats, expectedStats)
+					}
+					if len(failures) != len(expectedFailures) {
+						t.Errorf("Failures with %d jobs differ: %v, expected %v", jobs, failures, expectedFailures)
+					}
+					for i := range failures {
+						if i < len(expectedFailures) && failures[i].RelPath != expectedFailures[i].RelPath {
+							t.Errorf("Failure %d with %d jobs is %s, expected %s", i, jobs, failures[i].RelPath, expectedFailures[i].RelPath)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFormatter_JobsReadError(t *testing.T) {
+	root, entries := createParallelTestFiles(t)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Jobs: 4})
+	err := formatter.Format(root, entries)
+	expected := "failed to read file " + filepath.Join("dir01", "file12.bin")
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error for the first missing file, got %v", err)
+	}
+}
+
+func TestFormatSplit_Jobs(t *testing.T) {
+	format := func(jobs int) [][]byte {
+		root, entries := createParallelTestFiles(t)
+		formatter := NewFormatterWithOptions(nil, Options{SplitSize: 4096, KeepGoing: true, Jobs: jobs, MaxMemory: 1024})
+		parts, err := formatter.FormatSplit(root, entries)
+		if err != nil {
+			t.Fatalf("FormatSplit failed: %v", err)
+		}
+		return parts
+	}
+
+	expected := format(1)
+	parts := format(4)
+	if len(parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(parts))
+	}
+	for i := range parts {
+		if !bytes.Equal(parts[i], expected[i]) {
+			t.Errorf("Part %d differs from sequential output", i+1)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	m := newMemoryLimit(100)
+
+	first, ok := m.acquire(60)
+	if !ok || first != 60 {
+		t.Fatalf("Expected 60 bytes reserved, got %d (%v)", first, ok)
+	}
+
+	// Larger than the limit: reserves the whole limit once nothing is held
+	acquired := make(chan int64)
+	go func() {
+		reserved, _ := m.acquire(500)
+		acquired <- reserved
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected acquire to wait while memory is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.release(first)
+	if reserved := <-acquired; reserved != 100 {
+		t.Errorf("Expected the whole limit reserved, got %d", reserved)
+	}
+
+	// Closing fails waiting acquisitions
+	failed := make(chan bool)
+	go func() {
+		_, ok := m.acquire(1)
+		failed <- !ok
+	}()
+	m.close()
+	if !<-failed {
+		t.Error("Expected acquire to fail after close")
+	}
+}
@@ -0,0 +1,295 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// maxPartsForOverhead is used for the "part i of n" header when measuring the
+// size of a part without files, so that the actual header never exceeds it.
+const maxPartsForOverhead = 99999
+
+// splitter packs files into parts that fit in the split size or token budget.
+type splitter struct {
+	formatter    *Formatter
+	newRenderer  func(io.Writer) renderer
+	root         *tree.Node
+	renderedTree string
+	available    int // Budget left for files in each part
+
+	// Renderers collecting files until finished (json, template) are measured
+	// with a tree without children, so that measuring a file doesn't render
+	// the whole tree
+	bareRoot *tree.Node
+	bareCost int // Cost of a part with bareRoot and no files
+}
+
+// FormatSplit formats the output split into parts and returns the content of
+// each part. Every part starts with a "part i of n" header followed by the
+// whole tree. Files are never split across parts unless a single file exceeds
+// the budget on its own, in which case it is split at line boundaries and each
+// piece is labeled with its line range.
+//
+// Parts are limited by Options.SplitSize (bytes) or Options.SplitTokens
+// (tokens, requires Options.Tokenizer). A part exceeds the limit only when a
+// single line doesn't fit.
+func (f *Formatter) FormatSplit(treeRoot *tree.Node, entries []scanner.FileEntry) ([][]byte, error) {
+	if f.splitSize <= 0 && f.splitTokens <= 0 {
+		return nil, fmt.Errorf("split size or split tokens must be specified")
+	}
+	if f.splitTokens > 0 && f.tokenizer == nil {
+		return nil, fmt.Errorf("split tokens requires a tokenizer")
+	}
+
+	treeRoot, entries, read, err := f.prepare(treeRoot, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &splitter{
+		formatter:    f,
+		root:         treeRoot,
+		renderedTree: tree.Render(treeRoot),
+		bareRoot:     &tree.Node{Name: treeRoot.Name, Path: treeRoot.Path, IsDir: true},
+	}
+
+	f.stats = nil
+	budget := f.newTokenBudget(s.renderedTree)
+
+	var files []*fileData
+	reader := f.openFiles(entries, read)
+	defer reader.close()
+	for {
+		file, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			break
+		}
+		if f.fitFile(file, budget) {
+			files = append(files, file)
+		}
+	}
+
+	// The files are all in memory, so the plain boundary is chosen from them
+	boundary, err := f.chooseBoundaryFor(files)
+	if err != nil {
+		return nil, err
+	}
+	s.newRenderer = f.rendererFactory(boundary)
+
+	overhead, err := s.measure(nil, maxPartsForOverhead, maxPartsForOverhead)
+	if err != nil {
+		return nil, err
+	}
+	s.available = s.limit() - overhead
+	if s.available <= 0 {
+		return nil, fmt.Errorf("split limit %d is too small for the tree (%d needed)", s.limit(), overhead)
+	}
+	if s.bareCost, err = s.measureBare(nil); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.pack(files)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([][]byte, len(groups))
+	for i, group := range groups {
+		part, err := s.render(group, i+1, len(groups))
+		if err != nil {
+			return nil, err
+		}
+		if parts[i], err = f.encode(part); err != nil {
+			return nil, fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return parts, nil
+}
+
+// limit returns the budget of a part in bytes or tokens.
+func (s *splitter) limit() int {
+	if s.formatter.splitTokens > 0 {
+		return s.formatter.splitTokens
+	}
+	return int(s.formatter.splitSize)
+}
+
+// cost returns the size of rendered output in bytes (after encoding with
+// the output encoding and line endings) or tokens.
+func (s *splitter) cost(rendered []byte) (int, error) {
+	if s.formatter.splitTokens > 0 {
+		return s.formatter.tokenizer.Count(string(rendered)), nil
+	}
+	encoded, err := s.formatter.encode(rendered)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	return len(encoded), nil
+}
+
+// render renders a part with the given files.
+func (s *splitter) render(files []*fileData, part, parts int) ([]byte, error) {
+	return s.renderTree(s.root, s.renderedTree, files, part, parts)
+}
+
+// renderTree renders a part with the given tree and files.
+func (s *splitter) renderTree(root *tree.Node, renderedTree string, files []*fileData, part, parts int) ([]byte, error) {
+	var buf bytes.Buffer
+	r := s.newRenderer(&buf)
+
+	if err := r.writePart(part, parts); err != nil {
+		return nil, fmt.Errorf("failed to write part header: %w", err)
+	}
+	if err := r.writeTree(root, renderedTree); err != nil {
+		return nil, fmt.Errorf("failed to write tree output: %w", err)
+	}
+	for _, file := range files {
+		if err := r.writeFile(file); err != nil {
+			return nil, fmt.Errorf("failed to write file content for %s: %w", file.RelPath, err)
+		}
+	}
+	if err := r.finish(); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// measure returns the cost of a part with the given files.
+func (s *splitter) measure(files []*fileData, part, parts int) (int, error) {
+	rendered, err := s.render(files, part, parts)
+	if err != nil {
+		return 0, err
+	}
+	return s.cost(rendered)
+}
+
+// measureBare returns the cost of a part with bareRoot and the given files.
+func (s *splitter) measureBare(files []*fileData) (int, error) {
+	rendered, err := s.renderTree(s.bareRoot, tree.Render(s.bareRoot), files, 1, 1)
+	if err != nil {
+		return 0, err
+	}
+	return s.cost(rendered)
+}
+
+// fileCost returns the cost a file adds to a part.
+func (s *splitter) fileCost(file *fileData) (int, error) {
+	var buf bytes.Buffer
+	if err := s.newRenderer(&buf).writeFile(file); err != nil {
+		return 0, fmt.Errorf("failed to write file content for %s: %w", file.RelPath, err)
+	}
+	if buf.Len() > 0 {
+		return s.cost(buf.Bytes())
+	}
+
+	// The renderer collects files until finished (json, template),
+	// so measure the difference to a part without files
+	with, err := s.measureBare([]*fileData{file})
+	if err != nil {
+		return 0, err
+	}
+	return with - s.bareCost, nil
+}
+
+// pack groups files into parts in order, splitting files that don't fit in a part on their own.
+func (s *splitter) pack(files []*fileData) ([][]*fileData, error) {
+	var groups [][]*fileData
+	var current []*fileData
+	used := 0
+
+	for _, file := range files {
+		cost, err := s.fileCost(file)
+		if err != nil {
+			return nil, err
+		}
+		if used+cost <= s.available {
+			current = append(current, file)
+			used += cost
+			continue
+		}
+
+		if len(current) > 0 {
+			groups = append(groups, current)
+			current, used = nil, 0
+		}
+		if cost <= s.available {
+			current, used = []*fileData{file}, cost
+			continue
+		}
+
+		// The file alone exceeds the budget: give each piece a part of its own,
+		// except the last one which may share its part with following files
+		pieces, err := s.splitFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, piece := range pieces[:len(pieces)-1] {
+			groups = append(groups, []*fileData{piece})
+		}
+		last := pieces[len(pieces)-1]
+		if used, err = s.fileCost(last); err != nil {
+			return nil, err
+		}
+		current = []*fileData{last}
+	}
+
+	if len(current) > 0 || len(groups) == 0 {
+		groups = append(groups, current)
+	}
+	return groups, nil
+}
+
+// splitFile splits a file at line boundaries into pieces that each fit in a part.
+// A piece holds at least one line, even if that line alone exceeds the budget.
+func (s *splitter) splitFile(file *fileData) ([]*fileData, error) {
+	if file.Placeholder != "" {
+		return []*fileData{file}, nil
+	}
+
+	lines := splitLines(file.Content)
+	var pieces []*fileData
+	var searchErr error
+
+	for start := 0; start < len(lines); {
+		// Find the first end whose piece no longer fits
+		remaining := len(lines) - start
+		n := sort.Search(remaining, func(i int) bool {
+			if searchErr != nil {
+				return true
+			}
+			cost, err := s.fileCost(newPiece(file, lines, start, start+i+1))
+			if err != nil {
+				searchErr = err
+				return true
+			}
+			return cost > s.available
+		})
+		if searchErr != nil {
+			return nil, searchErr
+		}
+
+		end := start + max(n, 1)
+		pieces = append(pieces, newPiece(file, lines, start, end))
+		start = end
+	}
+
+	return pieces, nil
+}
+
+// newPiece returns a copy of the file holding lines[start:end].
+func newPiece(file *fileData, lines [][]byte, start, end int) *fileData {
+	piece := *file
+	piece.Content = bytes.Join(lines[start:end], nil)
+	piece.FirstLine = start + 1
+	piece.LastLine = end
+	piece.TotalLines = len(lines)
+	return &piece
+}
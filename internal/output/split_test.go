@@ -0,0 +1,141 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/encoding"
+)
+
+func TestFormatter_FormatSplit(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "aaa\n", "bbb\n", "ccc\n")
+
+	// Each part fits the header, the tree and two files:
+	// header 29 bytes (measured with the largest part numbers) + tree 49 bytes + 2 files x 19 bytes
+	formatter := NewFormatterWithOptions(nil, Options{SplitSize: 29 + 49 + 38})
+	parts, err := formatter.FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	renderedTree := "├── a.txt\n├── b.txt\n└── c.txt\n\n"
+	expected := []string{
+		"--- part 1 of 2 ---\n\n" + renderedTree +
+			"=== a.txt ===\naaa\n\n" +
+			"=== b.txt ===\nbbb\n\n",
+		"--- part 2 of 2 ---\n\n" + renderedTree +
+			"=== c.txt ===\nccc\n\n",
+	}
+
+	if len(parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(parts))
+	}
+	for i, part := range parts {
+		if string(part) != expected[i] {
+			t.Errorf("Part %d mismatch.\nExpected:\n%s\n\nGot:\n%s", i+1, expected[i], string(part))
+		}
+	}
+}
+
+func TestFormatter_FormatSplitCRLF(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "aaa\n", "bbb\n", "ccc\n")
+
+	// The split size is measured after converting line endings:
+	// two files fit with LF (116 bytes), but only one with CRLF (header 31 + tree 53 + 22 per file)
+	formatter := NewFormatterWithOptions(nil, Options{SplitSize: 29 + 49 + 38, EOL: encoding.EOLCRLF})
+	parts, err := formatter.FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		if len(part) > 116 {
+			t.Errorf("Part %d exceeds the split size: %d bytes", i+1, len(part))
+		}
+		if strings.Count(string(part), "\n") != strings.Count(string(part), "\r\n") {
+			t.Errorf("Expected CRLF line endings in part %d, got:\n%q", i+1, part)
+		}
+	}
+}
+
+func TestFormatter_FormatSplitLargeFile(t *testing.T) {
+	root, entries := createTokenTestFiles(t, numberedLines(10))
+
+	// Files get 64 bytes per part: "=== a.txt (lines 1-4 of 10) ===\n" (32 bytes) + 4 lines (28 bytes) + "\n"
+	formatter := NewFormatterWithOptions(nil, Options{SplitSize: 29 + 17 + 64})
+	parts, err := formatter.FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	expected := []string{
+		"=== a.txt (lines 1-4 of 10) ===\nline 1\nline 2\nline 3\nline 4\n\n",
+		"=== a.txt (lines 5-8 of 10) ===\nline 5\nline 6\nline 7\nline 8\n\n",
+		"=== a.txt (lines 9-10 of 10) ===\nline 9\nline 10\n\n",
+	}
+
+	if len(parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(parts))
+	}
+	for i, part := range parts {
+		if !strings.HasSuffix(string(part), expected[i]) {
+			t.Errorf("Part %d mismatch.\nExpected suffix:\n%s\n\nGot:\n%s", i+1, expected[i], string(part))
+		}
+		if len(part) > 110 {
+			t.Errorf("Part %d exceeds the split size: %d bytes", i+1, len(part))
+		}
+	}
+}
+
+func TestFormatter_FormatSplitJSON(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "aaa\n", "bbb\n")
+
+	formatter := NewFormatterWithOptions(nil, Options{SplitSize: 1000, Format: FormatJSON})
+	parts, err := formatter.FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	if len(parts) != 1 {
+		t.Fatalf("Expected 1 part, got %d", len(parts))
+	}
+	if !strings.HasPrefix(string(parts[0]), "{\n  \"part\": 1,\n  \"parts\": 1,\n  \"tree\": {") {
+		t.Errorf("Expected part fields at the top, got:\n%s", string(parts[0]))
+	}
+}
+
+func TestFormatter_FormatSplitTooSmall(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "aaa\n")
+
+	formatter := NewFormatterWithOptions(nil, Options{SplitSize: 20})
+	_, err := formatter.FormatSplit(root, entries)
+	if err == nil {
+		t.Fatal("Expected error for a split size smaller than the tree")
+	}
+	if !strings.Contains(err.Error(), "too small") {
+		t.Errorf("Expected 'too small' error, got: %v", err)
+	}
+}
+
+func TestFormatter_FormatSplitTokens(t *testing.T) {
+	root, entries := createTokenTestFiles(t, numberedLines(20), numberedLines(20))
+
+	formatter := NewFormatterWithOptions(nil, Options{SplitTokens: 80, Tokenizer: newEstimateTokenizer(t)})
+	parts, err := formatter.FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	if len(parts) < 2 {
+		t.Fatalf("Expected multiple parts, got %d", len(parts))
+	}
+	tok := newEstimateTokenizer(t)
+	for i, part := range parts {
+		if tokens := tok.Count(string(part)); tokens > 80 {
+			t.Errorf("Part %d exceeds the token budget: %d tokens", i+1, tokens)
+		}
+	}
+}
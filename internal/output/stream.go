@@ -0,0 +1,322 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/scanner"
+	"golang.org/x/text/transform"
+)
+
+// streamThreshold is the size from which files are streamed to the output
+// instead of being read into memory, when the output allows it (see canStream).
+// It is a variable so that tests can lower it.
+var streamThreshold int64 = 8 << 20
+
+// streamBufferSize is the size of the buffers used to scan files.
+const streamBufferSize = 64 * 1024
+
+// canStream returns true if file contents can be streamed to the output
+// rather than held in memory: the output format writes each file as it is
+// read, and nothing needs the whole content (token counting, per-file
+// limits, tree stats or splitting into parts).
+func (f *Formatter) canStream() bool {
+	return f.template == nil && f.format != FormatJSON && f.format != FormatJSONL &&
+		f.tokenizer == nil && !f.treeStats && !f.hasFileLimits() &&
+		f.splitSize <= 0 && f.splitTokens <= 0
+}
+
+// streams returns true if a file of the given size is streamed to the output.
+func (f *Formatter) streams(size int64) bool {
+	return size >= streamThreshold && f.canStream()
+}
+
+// streamReader reads the converted content of a file and closes the file.
+type streamReader struct {
+	*encoding.Reader
+	io.Closer
+}
+
+// openStream opens a file, converting its content like readFile
+// (encoding conversion, invalid bytes handling, BOM removal and newline
+// normalization) while it is read.
+func openStream(path string, converter encoding.Converter, invalid encoding.InvalidBytes, eol encoding.EOL) (*streamReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{Reader: encoding.NewReader(file, converter, invalid, eol), Closer: file}, nil
+}
+
+// checkStream reads the converted content of a file in bounded buffers,
+// returning an *encoding.InvalidBytesError if it has invalid bytes.
+func checkStream(path string, converter encoding.Converter, invalid encoding.InvalidBytes, eol encoding.EOL) error {
+	stream, err := openStream(path, converter, invalid, eol)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	_, err = io.CopyBuffer(io.Discard, stream, make([]byte, streamBufferSize))
+	return err
+}
+
+// copyStream writes the converted content of a streamed file to w.
+func copyStream(w io.Writer, file *fileData) error {
+	return readStream(file, func(stream io.Reader) error {
+		_, err := io.Copy(w, stream)
+		return err
+	})
+}
+
+// readStream opens the converted content of a streamed file and passes it to
+// fn. The invalid bytes replaced or escaped are recorded in the file.
+func readStream(file *fileData, fn func(stream io.Reader) error) error {
+	stream, err := file.stream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if err := fn(stream); err != nil {
+		return err
+	}
+	file.invalid = stream.Invalid()
+	return nil
+}
+
+// streamInfo describes the converted content of a streamed file, which the
+// renderers need to know before writing it.
+type streamInfo struct {
+	size      int64 // Size in bytes
+	last      byte  // Last byte (when size > 0)
+	backticks int   // Longest run of backticks
+	markup    bool  // Whether it contains '<', '&' or "]]>"
+}
+
+// inspectStream reads the converted content of a streamed file to describe it.
+func inspectStream(file *fileData) (streamInfo, error) {
+	var info streamInfo
+	err := readStream(file, func(stream io.Reader) error {
+		buf := make([]byte, streamBufferSize)
+		run := 0
+		var prev1, prev2 byte // The two bytes before the current one
+		for {
+			n, err := stream.Read(buf)
+			for _, b := range buf[:n] {
+				if b == '`' {
+					run++
+					info.backticks = max(info.backticks, run)
+				} else {
+					run = 0
+				}
+				if b == '<' || b == '&' || (b == '>' && prev1 == ']' && prev2 == ']') {
+					info.markup = true
+				}
+				prev2, prev1 = prev1, b
+			}
+			if n > 0 {
+				info.size += int64(n)
+				info.last = buf[n-1]
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	return info, err
+}
+
+// xmlCharSanitizer replaces invalid UTF-8 and characters outside the XML
+// Char range with U+FFFD, like sanitizeXMLChars.
+type xmlCharSanitizer struct {
+	transform.NopResetter
+}
+
+func (xmlCharSanitizer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		r, size := utf8.DecodeRune(src[nSrc:])
+		if r == utf8.RuneError && size <= 1 && !atEOF && !utf8.FullRune(src[nSrc:]) {
+			// The rest of the sequence may be in the next buffer
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+
+		out := src[nSrc : nSrc+size]
+		if (r == utf8.RuneError && size <= 1) || !isXMLChar(r) {
+			out = []byte(string(utf8.RuneError))
+		}
+		if nDst+len(out) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], out)
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+
+// cdataEscaper splits "]]>" so that it can't terminate a CDATA section, like xmlText.
+type cdataEscaper struct {
+	transform.NopResetter
+}
+
+func (cdataEscaper) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	end := []byte("]]>")
+	escaped := []byte("]]]]><![CDATA[>")
+	for nSrc < len(src) {
+		rest := src[nSrc:]
+		if rest[0] == ']' && len(rest) < len(end) && !atEOF && bytes.HasPrefix(end, rest) {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+
+		out, size := rest[:1], 1
+		if bytes.HasPrefix(rest, end) {
+			out, size = escaped, len(end)
+		}
+		if nDst+len(out) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], out)
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+
+// markerScanner looks for lines that look like "=== ... ===" markers in
+// content written to it in chunks, so that files don't have to be read into
+// memory. Lines are compared without trailing CRs, like hasMarkerLine.
+type markerScanner struct {
+	found  bool
+	length int    // Length of the current line without trailing CRs
+	crs    int    // Trailing CRs of the current line
+	head   uint32 // First 4 bytes of the current line
+	tail   uint32 // Last 4 bytes of the current line without trailing CRs
+}
+
+const (
+	markerHead = uint32('=')<<24 | uint32('=')<<16 | uint32('=')<<8 | uint32(' ')
+	markerTail = uint32(' ')<<24 | uint32('=')<<16 | uint32('=')<<8 | uint32('=')
+)
+
+func (s *markerScanner) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch b {
+		case '\n':
+			s.endLine()
+		case '\r':
+			s.crs++
+		default:
+			for ; s.crs > 0; s.crs-- {
+				s.add('\r')
+			}
+			s.add(b)
+		}
+	}
+	return len(p), nil
+}
+
+// add appends a byte to the current line.
+func (s *markerScanner) add(b byte) {
+	if s.length < 4 {
+		s.head = s.head<<8 | uint32(b)
+	}
+	s.tail = s.tail<<8 | uint32(b)
+	s.length++
+}
+
+// endLine checks the current line and starts a new one.
+// It must be called at the end of the content for the last line.
+func (s *markerScanner) endLine() {
+	if s.length >= 8 && s.head == markerHead && s.tail == markerTail {
+		s.found = true
+	}
+	s.length, s.crs, s.head, s.tail = 0, 0, 0, 0
+}
+
+// openConverted opens a file, converting its content like readFile, so that
+// it can be checked as it is written. It returns nil for files written as a
+// binary placeholder. Invalid bytes failing or skipping the file are replaced,
+// since such content is not written.
+func (f *Formatter) openConverted(entry scanner.FileEntry) (*streamReader, error) {
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := readLeading(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	converter, _ := f.selectConverter(entry, sample)
+	invalid := f.invalidBytes
+	if sniffSample(sample, converter).Binary {
+		if f.binaryMode != BinaryInclude {
+			file.Close()
+			return nil, nil
+		}
+		invalid = encoding.InvalidKeep
+	}
+	if invalid == encoding.InvalidError || invalid == encoding.InvalidSkipFile {
+		invalid = encoding.InvalidReplace
+	}
+
+	reader := encoding.NewReader(io.MultiReader(bytes.NewReader(sample), file), converter, invalid, f.eol)
+	return &streamReader{Reader: reader, Closer: file}, nil
+}
+
+// scanConverted reports whether the converted content of a file has a line
+// that looks like a "=== ... ===" marker and whether it contains the data,
+// reading it once in bounded buffers.
+func (f *Formatter) scanConverted(entry scanner.FileEntry, data []byte) (marker, contains bool, err error) {
+	stream, err := f.openConverted(entry)
+	if err != nil || stream == nil {
+		return false, false, err
+	}
+	defer stream.Close()
+	return scanReader(stream, data)
+}
+
+// scanReader reports whether the content read from r has a line that looks
+// like a "=== ... ===" marker and whether it contains the data, reading it
+// in bounded buffers.
+func scanReader(r io.Reader, data []byte) (marker, contains bool, err error) {
+	var scanner markerScanner
+	if contains, err = readerContains(io.TeeReader(r, &scanner), data); err != nil {
+		return false, false, err
+	}
+	if contains {
+		// Check the rest for marker lines
+		if _, err := io.CopyBuffer(&scanner, r, make([]byte, streamBufferSize)); err != nil {
+			return false, false, err
+		}
+	}
+	scanner.endLine()
+	return scanner.found, contains, nil
+}
+
+// readerContains reports whether the content read from r contains the data,
+// reading it in bounded buffers.
+func readerContains(r io.Reader, data []byte) (bool, error) {
+	// Keep the end of the previous buffer to find data across buffers
+	overlap := len(data) - 1
+	buf := make([]byte, overlap+streamBufferSize)
+	kept := 0
+	for {
+		n, err := r.Read(buf[kept:])
+		window := buf[:kept+n]
+		if bytes.Contains(window, data) {
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		kept = copy(buf, window[max(0, len(window)-overlap):])
+	}
+}
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// createStreamTestFiles creates files whose content needs care when streamed:
+// markup and "]]>" across buffer boundaries, backtick runs, invalid UTF-8,
+// a BOM, CRLF line endings, Shift_JIS, UTF-16 and a missing trailing newline.
+func createStreamTestFiles(t *testing.T) (*tree.Node, []scanner.FileEntry) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	sjis, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(strings.Repeat("日本語\r\n", 3000)))
+	if err != nil {
+		t.Fatalf("Failed to encode Shift_JIS: %v", err)
+	}
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(strings.Repeat("Write-Host 日本語\r\n", 3000)))
+	if err != nil {
+		t.Fatalf("Failed to encode UTF-16: %v", err)
+	}
+
+	files := map[string][]byte{
+		"empty.txt":     {},
+		"plain.txt":     []byte(strings.Repeat("plain text\r\n", 2000) + "no newline"),
+		"cdata.xml":     []byte(strings.Repeat("x", 4094) + "]]>" + strings.Repeat("<a>&amp;</a>\n", 500)),
+		"cdata-end.xml": []byte("<root>" + strings.Repeat("y", 5000) + "]]"),
+		"fence.md":      []byte("```go\ncode\n```\n" + strings.Repeat("z", 4095) + "`````\n"),
+		"invalid.txt":   append([]byte(strings.Repeat("a", 4095)), "\xe3\x81\xff end\n"...),
+		"bom.txt":       append([]byte("\xEF\xBB\xBF"), strings.Repeat("bom line\r", 1000)...),
+		"sjis.txt":      sjis,
+		"utf16.ps1":     utf16,
+	}
+
+	var entries []scanner.FileEntry
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: name, Size: int64(len(content))})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelPath < entries[j].RelPath })
+
+	return tree.Build(entries, ""), entries
+}
+
+func TestFormatter_StreamingMatchesInMemory(t *testing.T) {
+	converter, err := encoding.NewConverter("shift_jis")
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"plain", Options{}},
+		{"markdown", Options{Format: FormatMarkdown}},
+		{"xml", Options{Format: FormatXML}},
+		{"shift_jis", Options{EncodingMap: map[string]encoding.Converter{"txt": converter}}},
+		{"escape", Options{InvalidBytes: encoding.InvalidEscape}},
+		{"skip-file", Options{InvalidBytes: encoding.InvalidSkipFile, Format: FormatJSON}},
+		{"output encoding", Options{OutputEncoding: converter, EOL: encoding.EOLCRLF, Format: FormatMarkdown}},
+		{"preserve", Options{EOL: encoding.EOLPreserve, Format: FormatXML}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, entries := createStreamTestFiles(t)
+
+			formatWithThreshold := func(threshold int64) string {
+				original := streamThreshold
+				streamThreshold = threshold
+				defer func() { streamThreshold = original }()
+
+				var buf bytes.Buffer
+				formatter := NewFormatterWithOptions(&buf, tt.options)
+				if err := formatter.Format(root, entries); err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				// Invalid bytes of streamed files are counted while they are written
+				return buf.String() + fmt.Sprintf("%+v", formatter.Stats())
+			}
+
+			expected := formatWithThreshold(1 << 40)
+			if result := formatWithThreshold(1); result != expected {
+				t.Errorf("Streamed output differs from in-memory output:\n%s", firstDifference(expected, result))
+			}
+		})
+	}
+}
+
+func TestFormatter_NoStreamingWhenContentNeeded(t *testing.T) {
+	original := streamThreshold
+	streamThreshold = 1
+	defer func() { streamThreshold = original }()
+
+	for _, options := range []Options{
+		{Format: FormatJSON},
+		{MaxFileLines: 10},
+		{TreeStats: true},
+		{SplitSize: 1000},
+	} {
+		formatter := NewFormatterWithOptions(nil, options)
+		if formatter.streams(100) {
+			t.Errorf("Expected no streaming with %+v", options)
+		}
+	}
+	if !NewFormatterWithOptions(nil, Options{}).streams(100) {
+		t.Error("Expected streaming for plain output")
+	}
+}
+
+// firstDifference describes where two outputs start to differ.
+func firstDifference(expected, actual string) string {
+	i := 0
+	for i < len(expected) && i < len(actual) && expected[i] == actual[i] {
+		i++
+	}
+	return fmt.Sprintf("at byte %d: expected %q, got %q",
+		i, expected[i:min(len(expected), i+40)], actual[i:min(len(actual), i+40)])
+}
+
+func TestScanReader(t *testing.T) {
+	padding := strings.Repeat("p", streamBufferSize-3)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"no marker", padding + "\n=== a", false},
+		{"marker across buffers", padding + "\n=== " + strings.Repeat("m", streamBufferSize) + " ===\r\r\n", true},
+		{"marker at end without newline", padding + "\n=== end ===", true},
+		{"CR inside marker", "=== a ===\r=== b", false},
+		{"short line", "=== ===\n", false},
+		{"marker after data", "treecat-boundary\n" + padding + "\n=== end ===", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, contains, err := scanReader(strings.NewReader(tt.content), []byte("treecat-boundary"))
+			if err != nil {
+				t.Fatalf("scanReader failed: %v", err)
+			}
+			if found != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, found)
+			}
+			if contains != strings.Contains(tt.content, "treecat-boundary") {
+				t.Errorf("Expected contains %v, got %v", !contains, contains)
+			}
+			if hasMarkerLine([]byte(tt.content)) != tt.expected {
+				t.Errorf("hasMarkerLine disagrees for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestReaderContains(t *testing.T) {
+	for _, offset := range []int{0, streamBufferSize - 5, streamBufferSize - 1, streamBufferSize, 3 * streamBufferSize} {
+		content := strings.Repeat("-", offset) + "treecat-boundary" + strings.Repeat("-", 100)
+		found, err := readerContains(strings.NewReader(content), []byte("treecat-boundary"))
+		if err != nil {
+			t.Fatalf("readerContains failed: %v", err)
+		}
+		if !found {
+			t.Errorf("Expected data at offset %d to be found", offset)
+		}
+
+		found, err = readerContains(strings.NewReader(content), []byte("treecat-other"))
+		if err != nil {
+			t.Fatalf("readerContains failed: %v", err)
+		}
+		if found {
+			t.Errorf("Expected no match at offset %d", offset)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package output
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// DefaultTemplate is the built-in template, printed by "treecat template show"
+// as a starting point for user-defined templates. It produces the same output
+// as the plain format, including part headers and line ranges when the output
+// is split, except that it always uses "=== path ===" markers (the plain format
+// switches to boundary markers when a file contains such a line).
+//
+//go:embed templates/plain.tmpl
+var DefaultTemplate string
+
+// TemplateData is the data passed to output templates.
+type TemplateData struct {
+	Tree  string       // Rendered directory tree
+	Root  *tree.Node   // Root node of the directory tree
+	Files []FileRecord // Files in output order
+	Part  int          // Part number when the output is split into parts (0 otherwise)
+	Parts int          // Number of parts when the output is split into parts (0 otherwise)
+}
+
+// FileRecord is a file passed to output templates.
+type FileRecord struct {
+	RelPath    string // Relative path with forward slashes
+	Size       int64  // Original file size in bytes
+	Language   string // Language derived from the file name (empty if unknown)
+	Encoding   string // Encoding the content was decoded from (empty for binary files)
+	Binary     bool   // Whether the file was detected as binary
+	Error      string // Why the file could not be read (Content is a placeholder line)
+	MIMEType   string // Detected MIME type (set for binary files)
+	Content    string // Converted content, or a placeholder line for binary files
+	LineCount  int    // Number of lines in Content
+	Truncated  bool   // Whether Content was truncated
+	FirstLine  int    // First line of Content when the file is split across parts (0 otherwise)
+	LastLine   int    // Last line of Content when the file is split across parts (0 otherwise)
+	TotalLines int    // Total lines of the file when it is split across parts (0 otherwise)
+}
+
+// ParseTemplate parses an output template from text.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// LoadTemplate reads and parses an output template file.
+func LoadTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return ParseTemplate(filepath.Base(path), string(text))
+}
+
+// templateRenderer collects all files and executes a template when finished.
+type templateRenderer struct {
+	writer   io.Writer
+	template *template.Template
+	data     TemplateData
+}
+
+func (r *templateRenderer) writePart(part, parts int) error {
+	r.data.Part = part
+	r.data.Parts = parts
+	return nil
+}
+
+func (r *templateRenderer) writeTree(root *tree.Node, rendered string) error {
+	r.data.Tree = rendered
+	r.data.Root = root
+	return nil
+}
+
+func (r *templateRenderer) writeFile(file *fileData) error {
+	record := FileRecord{
+		RelPath:    file.RelPath,
+		Size:       file.Size,
+		Language:   languageFor(file.RelPath),
+		Encoding:   file.Encoding,
+		Binary:     file.Binary,
+		Error:      file.Error,
+		MIMEType:   file.MIMEType,
+		Content:    string(file.Content),
+		Truncated:  file.Truncated,
+		FirstLine:  file.FirstLine,
+		LastLine:   file.LastLine,
+		TotalLines: file.TotalLines,
+	}
+	if file.Placeholder != "" {
+		record.Content = file.Placeholder + "\n"
+	}
+	record.LineCount = countLines([]byte(record.Content))
+
+	r.data.Files = append(r.data.Files, record)
+	return nil
+}
+
+func (r *templateRenderer) finish() error {
+	if err := r.template.Execute(r.writer, r.data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
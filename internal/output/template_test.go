@@ -0,0 +1,161 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+func TestFormatter_DefaultTemplateMatchesPlain(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Files with and without trailing newline, nested, and binary
+	if err := os.MkdirAll(filepath.Join(tmpDir, "dir"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	files := map[string][]byte{
+		"a.txt":        []byte("no newline"),
+		"dir/b.go":     []byte("package dir\n\nfunc A() {}\n\nfunc B() {}\n\nfunc C() {}\n"),
+		"dir/logo.png": append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...),
+	}
+	entries := []scanner.FileEntry{
+		{Path: filepath.Join(tmpDir, "a.txt"), RelPath: "a.txt"},
+		{Path: filepath.Join(tmpDir, "dir"), RelPath: "dir", IsDir: true},
+		{Path: filepath.Join(tmpDir, "dir", "b.go"), RelPath: filepath.Join("dir", "b.go")},
+		{Path: filepath.Join(tmpDir, "dir", "logo.png"), RelPath: filepath.Join("dir", "logo.png")},
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	root := tree.Build(entries, "project")
+
+	var plain bytes.Buffer
+	if err := NewFormatterWithOptions(&plain, Options{}).Format(root, entries); err != nil {
+		t.Fatalf("Format (plain) failed: %v", err)
+	}
+
+	tmpl, err := ParseTemplate("default", DefaultTemplate)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	var templated bytes.Buffer
+	if err := NewFormatterWithOptions(&templated, Options{Template: tmpl}).Format(root, entries); err != nil {
+		t.Fatalf("Format (template) failed: %v", err)
+	}
+
+	if templated.String() != plain.String() {
+		t.Errorf("Default template output differs from plain.\nPlain:\n%q\n\nTemplate:\n%q", plain.String(), templated.String())
+	}
+
+	// Part headers and line ranges when split
+	plainParts, err := NewFormatterWithOptions(nil, Options{SplitSize: 120}).FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit (plain) failed: %v", err)
+	}
+	templatedParts, err := NewFormatterWithOptions(nil, Options{SplitSize: 120, Template: tmpl}).FormatSplit(root, entries)
+	if err != nil {
+		t.Fatalf("FormatSplit (template) failed: %v", err)
+	}
+	if !bytes.Contains(bytes.Join(plainParts, nil), []byte("=== dir/b.go (lines 1-")) {
+		t.Fatalf("Expected b.go to be split across parts, got %q", plainParts)
+	}
+	if len(templatedParts) != len(plainParts) {
+		t.Fatalf("Expected %d parts, got %d", len(plainParts), len(templatedParts))
+	}
+	for i := range plainParts {
+		if !bytes.Equal(templatedParts[i], plainParts[i]) {
+			t.Errorf("Part %d differs from plain.\nPlain:\n%q\n\nTemplate:\n%q", i+1, plainParts[i], templatedParts[i])
+		}
+	}
+}
+
+func TestFormatter_CustomTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goFile := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create go file: %v", err)
+	}
+	entries := []scanner.FileEntry{
+		{Path: goFile, RelPath: "main.go"},
+	}
+	root := tree.Build(entries, "project")
+
+	text := `root={{.Root.Name}} children={{len .Root.Children}}
+{{range .Files}}<file path="{{.RelPath}}" lang="{{.Language}}" size="{{.Size}}" lines="{{.LineCount}}">
+{{.Content}}</file>
+{{end}}`
+	tmpl, err := ParseTemplate("custom", text)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewFormatterWithOptions(&buf, Options{Template: tmpl}).Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `root=project children=1
+<file path="main.go" lang="go" size="29" lines="3">
+package main
+
+func main() {}
+</file>
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	_, err := ParseTemplate("invalid", "{{range .Files}}")
+	if err == nil {
+		t.Fatal("Expected error for invalid template")
+	}
+	if !strings.Contains(err.Error(), "failed to parse template") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestFormatter_TemplateExecuteError(t *testing.T) {
+	tmpl, err := ParseTemplate("unknown-field", "{{.Unknown}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	root := &tree.Node{Name: "", IsDir: true}
+	err = NewFormatterWithOptions(&buf, Options{Template: tmpl}).Format(root, []scanner.FileEntry{})
+	if err == nil {
+		t.Fatal("Expected error for unknown field")
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	templatePath := filepath.Join(tmpDir, "custom.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{len .Files}} files\n"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	tmpl, err := LoadTemplate(templatePath)
+	if err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	if tmpl.Name() != "custom.tmpl" {
+		t.Errorf("Name() = %q, want %q", tmpl.Name(), "custom.tmpl")
+	}
+
+	if _, err := LoadTemplate(filepath.Join(tmpDir, "missing.tmpl")); err == nil {
+		t.Error("Expected error for missing template file")
+	}
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"fmt"
+)
+
+// tokenBudget counts tokens of the files being written and enforces the
+// maximum number of tokens. Files are added in order until the budget is
+// reached; the file crossing the budget is truncated to fit and all files
+// after it are dropped.
+type tokenBudget struct {
+	formatter *Formatter
+	used      int
+	exhausted bool
+}
+
+// newTokenBudget creates a budget with the tokens of the rendered tree already used.
+func (f *Formatter) newTokenBudget(renderedTree string) *tokenBudget {
+	f.treeTokens = 0
+	if f.tokenizer != nil {
+		f.treeTokens = f.tokenizer.Count(renderedTree)
+	}
+	return &tokenBudget{formatter: f, used: f.treeTokens}
+}
+
+// fit counts the tokens of the file and truncates or drops it when it doesn't
+// fit in the remaining budget. The file content is modified in place when truncated.
+func (b *tokenBudget) fit(file *fileData) FileStats {
+	stats := FileStats{RelPath: file.RelPath}
+
+	tok := b.formatter.tokenizer
+	if tok == nil {
+		return stats
+	}
+
+	maxTokens := b.formatter.maxTokens
+	if b.exhausted {
+		stats.Dropped = true
+		return stats
+	}
+
+	// Count the separator as well as the content (approximate for non-plain formats)
+	header := tok.Count(fmt.Sprintf("=== %s ===\n", file.RelPath))
+	tokens := header
+	if file.tokensCounted {
+		tokens += file.tokens
+	} else {
+		tokens += tok.Count(string(fileText(file)))
+	}
+
+	if maxTokens > 0 && b.used+tokens > maxTokens {
+		b.exhausted = true
+
+		truncated, ok := b.truncate(file, maxTokens-b.used-header)
+		if !ok {
+			stats.Dropped = true
+			return stats
+		}
+		file.Content = truncated
+		file.Truncated = true
+		stats.Truncated = true
+		tokens = header + tok.Count(string(truncated))
+	}
+
+	b.used += tokens
+	stats.Tokens = tokens
+	return stats
+}
+
+// truncate returns the longest leading lines of the content that fit in the
+// given number of tokens together with a truncation marker.
+// Returns false if not even a single line fits, or the file has no text content.
+func (b *tokenBudget) truncate(file *fileData, available int) ([]byte, bool) {
+	if file.Placeholder != "" || available <= 0 {
+		return nil, false
+	}
+
+	tok := b.formatter.tokenizer
+	lines := splitLines(file.Content)
+
+	build := func(keep int) []byte {
+		var result []byte
+		for _, line := range lines[:keep] {
+			result = append(result, line...)
+		}
+		return append(result, truncationMarker(len(lines)-keep, "token budget reached")...)
+	}
+
+	// Binary search for the number of lines that fits
+	low, high := 0, len(lines)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if tok.Count(string(build(mid))) <= available {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	if low == 0 {
+		return nil, false
+	}
+	return build(low), true
+}
+
+// fileText returns the text written for the file (content or placeholder).
+func fileText(file *fileData) []byte {
+	if file.Placeholder != "" {
+		return []byte(file.Placeholder + "\n")
+	}
+	return file.Content
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tokenizer"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// createTokenTestFiles creates files with the given contents in order.
+func createTokenTestFiles(t *testing.T, contents ...string) (*tree.Node, []scanner.FileEntry) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	var entries []scanner.FileEntry
+	for i, content := range contents {
+		name := string(rune('a'+i)) + ".txt"
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: name})
+	}
+
+	return tree.Build(entries, ""), entries
+}
+
+func newEstimateTokenizer(t *testing.T) tokenizer.Tokenizer {
+	t.Helper()
+	tok, err := tokenizer.New("estimate")
+	if err != nil {
+		t.Fatalf("Failed to create tokenizer: %v", err)
+	}
+	return tok
+}
+
+func TestFormatter_TokenStats(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "12345678\n", "1234\n")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Tokenizer: newEstimateTokenizer(t)})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// "=== a.txt ===\n" is 14 chars (4 tokens), contents are 9 chars (3 tokens) and 5 chars (2 tokens)
+	expected := []FileStats{
+		{RelPath: "a.txt", Encoding: "utf-8", Tokens: 7},
+		{RelPath: "b.txt", Encoding: "utf-8", Tokens: 6},
+	}
+	stats := formatter.Stats()
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d stats, got %d", len(expected), len(stats))
+	}
+	for i := range expected {
+		if stats[i] != expected[i] {
+			t.Errorf("Stats[%d] = %+v, want %+v", i, stats[i], expected[i])
+		}
+	}
+
+	// Tree "├── a.txt\n└── b.txt\n" is 20 chars (5 tokens)
+	if formatter.TotalTokens() != 18 {
+		t.Errorf("TotalTokens() = %d, want 18", formatter.TotalTokens())
+	}
+}
+
+func TestFormatter_MaxTokensTruncateAndDrop(t *testing.T) {
+	root, entries := createTokenTestFiles(t,
+		"1234567\n",
+		strings.Repeat("1234567\n", 50),
+		"dropped\n",
+	)
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{
+		Tokenizer: newEstimateTokenizer(t),
+		MaxTokens: 40,
+	})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	stats := formatter.Stats()
+	if stats[0].Truncated || stats[0].Dropped {
+		t.Errorf("Expected a.txt to be written as-is: %+v", stats[0])
+	}
+	if !stats[1].Truncated {
+		t.Errorf("Expected b.txt to be truncated: %+v", stats[1])
+	}
+	if !stats[2].Dropped {
+		t.Errorf("Expected c.txt to be dropped: %+v", stats[2])
+	}
+	if formatter.TotalTokens() > 40 {
+		t.Errorf("TotalTokens() = %d, exceeds budget", formatter.TotalTokens())
+	}
+
+	result := buf.String()
+	if !strings.Contains(result, "=== b.txt ===\n1234567\n") {
+		t.Error("Expected leading lines of b.txt")
+	}
+	if !strings.Contains(result, "lines: token budget reached] …\n") {
+		t.Error("Expected truncation marker")
+	}
+	if strings.Contains(result, "=== c.txt ===") {
+		t.Error("Expected c.txt to be dropped from contents")
+	}
+	// The tree still lists all files
+	if !strings.Contains(result, "└── c.txt") {
+		t.Error("Expected c.txt in tree")
+	}
+}
+
+func TestFormatter_MaxTokensDropWhenNothingFits(t *testing.T) {
+	root, entries := createTokenTestFiles(t, strings.Repeat("x", 400)+"\n", "small\n")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{
+		Tokenizer: newEstimateTokenizer(t),
+		MaxTokens: 20,
+	})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// The single line doesn't fit, so the file is dropped along with the rest
+	for _, stat := range formatter.Stats() {
+		if !stat.Dropped {
+			t.Errorf("Expected %s to be dropped: %+v", stat.RelPath, stat)
+		}
+	}
+	if strings.Contains(buf.String(), "===") {
+		t.Errorf("Expected no file contents, got:\n%s", buf.String())
+	}
+}
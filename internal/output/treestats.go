@@ -0,0 +1,63 @@
+package output
+
+import (
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tokenizer"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// annotateTree reads every file and annotates the tree before it is rendered:
+// with sizes, line counts and tokens aggregated up through directories when
+// TreeStats is set (tokens are counted with the configured tokenizer, or
+// estimated when none is set), and with the files truncated by the per-file limits.
+// The files are returned truncated and kept in memory to be written, so that
+// they are read only once.
+func (f *Formatter) annotateTree(root *tree.Node, entries []scanner.FileEntry) ([]*fileData, error) {
+	tok := f.tokenizer
+	if tok == nil {
+		tok = tokenizer.NewEstimator()
+	}
+
+	read := make([]*fileData, 0, len(entries))
+	files := make(map[string]tree.Stats)
+	reader := f.newFileReader(entries)
+	defer reader.close()
+	for {
+		file, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			break
+		}
+		read = append(read, file)
+
+		if file.Error != "" {
+			continue
+		}
+
+		if f.treeStats {
+			stats := tree.Stats{Size: file.Size, Binary: file.Binary}
+			if !file.Binary {
+				stats.Lines = countLines(file.Content)
+				stats.Tokens = tok.Count(string(file.Content))
+				if f.tokenizer != nil {
+					// Reused by the token budget unless the content is truncated
+					file.tokens, file.tokensCounted = stats.Tokens, true
+				}
+			}
+			files[file.RelPath] = stats
+		}
+
+		if f.truncateFile(file) {
+			if node := tree.Find(root, file.RelPath); node != nil {
+				node.Truncated = true
+			}
+		}
+	}
+
+	if f.treeStats {
+		tree.Annotate(root, files)
+	}
+	return read, nil
+}
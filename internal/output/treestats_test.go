@@ -0,0 +1,94 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+func TestFormatter_TreeStats(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string][]byte{
+		"main.go":          []byte("package main\n\nfunc main() {}\n"),
+		"assets/logo.png":  append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 2040)...),
+		"assets/style.css": []byte("body {}\n"),
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: filepath.Join(tmpDir, "assets"), RelPath: "assets", IsDir: true},
+		{Path: filepath.Join(tmpDir, "assets", "logo.png"), RelPath: filepath.Join("assets", "logo.png"), Size: 2048},
+		{Path: filepath.Join(tmpDir, "assets", "style.css"), RelPath: filepath.Join("assets", "style.css"), Size: 8},
+		{Path: filepath.Join(tmpDir, "main.go"), RelPath: "main.go", Size: 29},
+	}
+	root := tree.Build(entries, "project")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{TreeStats: true})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Tokens are estimated (4 characters per token) without a tokenizer
+	expectedTree := `project (3 files, 4 lines, 2.0 KiB, ~10 tok)
+├── assets/ (2 files, 1 line, 2.0 KiB, ~2 tok)
+│   ├── logo.png (binary, 2.0 KiB)
+│   └── style.css (1 line, 8 B, ~2 tok)
+└── main.go (3 lines, 29 B, ~8 tok)
+
+`
+	if !strings.HasPrefix(buf.String(), expectedTree) {
+		t.Errorf("Tree mismatch.\nExpected:\n%s\nGot:\n%s", expectedTree, buf.String())
+	}
+}
+
+// countingTokenizer counts the texts it is asked to count.
+type countingTokenizer struct {
+	counted map[string]int
+}
+
+func (c *countingTokenizer) Count(text string) int {
+	c.counted[text]++
+	return len(text)
+}
+
+func (c *countingTokenizer) Name() string {
+	return "counting"
+}
+
+func TestFormatter_TreeStatsCountsOnce(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "aaa\n", "bbb\nccc\nddd\n")
+
+	tok := &countingTokenizer{counted: map[string]int{}}
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{TreeStats: true, Tokenizer: tok, MaxFileLines: 2})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Files are counted for the tree and reused by the token budget,
+	// except the truncated one counted again for the output
+	if tok.counted["aaa\n"] != 1 {
+		t.Errorf("Expected a.txt to be counted once, got %d", tok.counted["aaa\n"])
+	}
+	if tok.counted["bbb\nccc\nddd\n"] != 1 {
+		t.Errorf("Expected b.txt to be counted once, got %d", tok.counted["bbb\nccc\nddd\n"])
+	}
+	if !strings.Contains(buf.String(), "=== b.txt ===\nbbb\n… [truncated 1 line] …\nddd\n") {
+		t.Errorf("Expected b.txt to be truncated once, got:\n%s", buf.String())
+	}
+}
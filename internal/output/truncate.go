@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// hasFileLimits returns true if per-file truncation limits are set.
+func (f *Formatter) hasFileLimits() bool {
+	return f.maxFileLines > 0 || f.maxFileBytes > 0
+}
+
+// truncateFile keeps the first and last lines of the content within the
+// per-file limits and replaces the lines in between with a truncation marker.
+// Each limit is split evenly between the head and the tail (the head gets the
+// odd line or byte). If the first or last line alone exceeds its half of the
+// byte limit, the line is cut at the limit instead, so that a file of one long
+// line (e.g., minified code) keeps some content. Returns true if the content
+// was truncated. Content already truncated is left as it is.
+func (f *Formatter) truncateFile(file *fileData) bool {
+	if file.Placeholder != "" || file.Truncated || !f.hasFileLimits() {
+		return false
+	}
+
+	lines := splitLines(file.Content)
+	if (f.maxFileLines <= 0 || len(lines) <= f.maxFileLines) &&
+		(f.maxFileBytes <= 0 || int64(len(file.Content)) <= f.maxFileBytes) {
+		return false
+	}
+
+	headLines, tailLines := -1, -1
+	if f.maxFileLines > 0 {
+		tailLines = f.maxFileLines / 2
+		headLines = f.maxFileLines - tailLines
+	}
+	headBytes, tailBytes := int64(-1), int64(-1)
+	if f.maxFileBytes > 0 {
+		tailBytes = f.maxFileBytes / 2
+		headBytes = f.maxFileBytes - tailBytes
+	}
+
+	head := countFitting(lines, headLines, headBytes)
+	tail := countFitting(reverseLines(lines[head:]), tailLines, tailBytes)
+	removed := lines[head : len(lines)-tail]
+
+	// Cut the first or last removed line if it is the one exceeding the byte limit
+	var headPart, tailPart []byte
+	if head == 0 && headBytes > 0 {
+		headPart = cutHead(removed[0], headBytes)
+	}
+	if tail == 0 && tailLines != 0 && tailBytes > 0 {
+		tailPart = cutTail(removed[len(removed)-1], tailBytes)
+	}
+
+	var result []byte
+	for _, line := range lines[:head] {
+		result = append(result, line...)
+	}
+	if headPart != nil || tailPart != nil {
+		size := -len(headPart) - len(tailPart)
+		for _, line := range removed {
+			size += len(line)
+		}
+		if len(headPart) > 0 {
+			result = append(append(result, headPart...), '\n')
+		}
+		result = append(result, bytesTruncationMarker(size)...)
+		result = append(result, tailPart...)
+	} else {
+		result = append(result, truncationMarker(len(removed), "")...)
+	}
+	for _, line := range lines[len(lines)-tail:] {
+		result = append(result, line...)
+	}
+
+	file.Content = result
+	file.Truncated = true
+	file.tokensCounted = false
+	return true
+}
+
+// countFitting returns how many leading lines fit in maxLines and maxBytes
+// (negative for unlimited).
+func countFitting(lines [][]byte, maxLines int, maxBytes int64) int {
+	var size int64
+	for i, line := range lines {
+		if maxLines >= 0 && i >= maxLines {
+			return i
+		}
+		size += int64(len(line))
+		if maxBytes >= 0 && size > maxBytes {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// cutHead returns the leading bytes of line within maxBytes, ending on a
+// UTF-8 character boundary.
+func cutHead(line []byte, maxBytes int64) []byte {
+	n := int(min(maxBytes, int64(len(line))))
+	for n > 0 && n < len(line) && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n]
+}
+
+// cutTail returns the trailing bytes of line within maxBytes, starting on a
+// UTF-8 character boundary.
+func cutTail(line []byte, maxBytes int64) []byte {
+	start := len(line) - int(min(maxBytes, int64(len(line))))
+	for start < len(line) && !utf8.RuneStart(line[start]) {
+		start++
+	}
+	return line[start:]
+}
+
+// reverseLines returns a copy of lines in reverse order.
+func reverseLines(lines [][]byte) [][]byte {
+	reversed := make([][]byte, len(lines))
+	for i, line := range lines {
+		reversed[len(lines)-1-i] = line
+	}
+	return reversed
+}
+
+// splitLines splits content into lines, each keeping its trailing newline.
+// The last line is terminated with a newline if missing, so lines can be joined
+// with a marker in between.
+func splitLines(content []byte) [][]byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if n := len(lines); n > 0 && !bytes.HasSuffix(lines[n-1], []byte("\n")) {
+		lines[n-1] = append(lines[n-1][:len(lines[n-1]):len(lines[n-1])], '\n')
+	}
+	return lines
+}
+
+// truncationMarker returns the line replacing truncated lines,
+// e.g. "… [truncated 18,234 lines] …". reason is appended after a colon if not empty.
+func truncationMarker(lines int, reason string) string {
+	text := message.NewPrinter(language.English).Sprintf("truncated %d lines", lines)
+	if lines == 1 {
+		text = "truncated 1 line"
+	}
+	if reason != "" {
+		text += ": " + reason
+	}
+	return "… [" + text + "] …\n"
+}
+
+// bytesTruncationMarker returns the line replacing truncated content when a
+// line is cut, e.g. "… [truncated 4,000 bytes] …".
+func bytesTruncationMarker(size int) string {
+	text := message.NewPrinter(language.English).Sprintf("truncated %d bytes", size)
+	if size == 1 {
+		text = "truncated 1 byte"
+	}
+	return "… [" + text + "] …\n"
+}
@@ -0,0 +1,133 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns "line 1\n" to "line n\n".
+func numberedLines(n int) string {
+	var builder strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&builder, "line %d\n", i)
+	}
+	return builder.String()
+}
+
+func TestFormatter_MaxFileLines(t *testing.T) {
+	root, entries := createTokenTestFiles(t, numberedLines(10), numberedLines(3))
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{MaxFileLines: 5})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `├── a.txt [truncated]
+└── b.txt
+
+=== a.txt ===
+line 1
+line 2
+line 3
+… [truncated 5 lines] …
+line 9
+line 10
+
+=== b.txt ===
+line 1
+line 2
+line 3
+
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_MaxFileBytes(t *testing.T) {
+	// 7 bytes per line for "line 1" to "line 9"
+	root, entries := createTokenTestFiles(t, numberedLines(9))
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{MaxFileBytes: 30, Format: FormatJSONL})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `{"path":"a.txt","size":63,"encoding":"utf-8","line_count":5,"truncated":true,"content":"line 1\nline 2\n… [truncated 5 lines] …\nline 8\nline 9\n"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_MaxFileBytesLongLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxBytes int64
+		expected string
+	}{
+		{"single line", "abcdefghijklmnopqrst\n", 10, "abcde\n… [truncated 11 bytes] …\nqrst\n"},
+		{"single line without newline", "abcdefghijklmnopqrst", 10, "abcde\n… [truncated 11 bytes] …\nqrst\n"},
+		{"UTF-8 boundary", "あいうえお\n", 8, "あ\n… [truncated 9 bytes] …\nお\n"},
+		{"long first line", "abcdefghijklmnopqrst\nb\nc\n", 10, "abcde\n… [truncated 16 bytes] …\nb\nc\n"},
+		{"long last line", "a\nb\nabcdefghijklmnopqrst\n", 10, "a\nb\n… [truncated 16 bytes] …\nqrst\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatterWithOptions(&bytes.Buffer{}, Options{MaxFileBytes: tt.maxBytes})
+			file := &fileData{Content: []byte(tt.content)}
+			if !formatter.truncateFile(file) {
+				t.Fatal("Expected the content to be truncated")
+			}
+			if string(file.Content) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, file.Content)
+			}
+		})
+	}
+}
+
+func TestFormatter_MaxFileLinesWithoutTrailingNewline(t *testing.T) {
+	root, entries := createTokenTestFiles(t, "a\nb\nc\nd")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{MaxFileLines: 1})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `└── a.txt [truncated]
+
+=== a.txt ===
+a
+… [truncated 3 lines] …
+
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestTruncationMarker(t *testing.T) {
+	tests := []struct {
+		lines    int
+		reason   string
+		expected string
+	}{
+		{1, "", "… [truncated 1 line] …\n"},
+		{18234, "", "… [truncated 18,234 lines] …\n"},
+		{5, "token budget reached", "… [truncated 5 lines: token budget reached] …\n"},
+	}
+
+	for _, tt := range tests {
+		if got := truncationMarker(tt.lines, tt.reason); got != tt.expected {
+			t.Errorf("truncationMarker(%d, %q) = %q, want %q", tt.lines, tt.reason, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/onozaty/treecat/internal/tree"
+	"golang.org/x/text/transform"
+)
+
+// xmlRenderer writes the tree and files in the document structure recommended
+// for Claude prompts:
+//
+//	<documents>
+//	<directory_tree>...</directory_tree>
+//	<document index="1">
+//	<source>path</source>
+//	<document_content>...</document_content>
+//	</document>
+//	</documents>
+type xmlRenderer struct {
+	writer io.Writer
+	index  int
+}
+
+func (r *xmlRenderer) writePart(part, parts int) error {
+	_, err := fmt.Fprintf(r.writer, "<!-- part %d of %d -->\n", part, parts)
+	return err
+}
+
+func (r *xmlRenderer) writeTree(root *tree.Node, rendered string) error {
+	_, err := fmt.Fprintf(r.writer, "<documents>\n<directory_tree>\n%s</directory_tree>\n", xmlText([]byte(rendered)))
+	return err
+}
+
+func (r *xmlRenderer) writeFile(file *fileData) error {
+	r.index++
+
+	var source bytes.Buffer
+	if err := xml.EscapeText(&source, []byte(file.displayPath())); err != nil {
+		return err
+	}
+
+	if file.stream != nil {
+		return r.writeStream(file, source.String())
+	}
+
+	content := file.Content
+	if file.Placeholder != "" {
+		content = []byte(file.Placeholder)
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content[:len(content):len(content)], '\n')
+	}
+
+	_, err := fmt.Fprintf(r.writer,
+		"<document index=\"%d\">\n<source>%s</source>\n<document_content>\n%s</document_content>\n</document>\n",
+		r.index, source.String(), xmlText(content))
+	return err
+}
+
+// writeStream writes a streamed file like writeFile, reading it twice:
+// first to find out whether it needs a CDATA section, then to write it.
+func (r *xmlRenderer) writeStream(file *fileData, source string) error {
+	info, err := inspectStream(file)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(r.writer, "<document index=\"%d\">\n<source>%s</source>\n<document_content>\n", r.index, source); err != nil {
+		return err
+	}
+
+	err = readStream(file, func(stream io.Reader) error {
+		if !info.markup {
+			if _, err := io.Copy(r.writer, transform.NewReader(stream, xmlCharSanitizer{})); err != nil {
+				return err
+			}
+			if info.size > 0 && info.last != '\n' {
+				_, err := io.WriteString(r.writer, "\n")
+				return err
+			}
+			return nil
+		}
+
+		// Keep the trailing newline outside of the CDATA section
+		if info.last == '\n' {
+			stream = io.LimitReader(stream, info.size-1)
+		}
+		if _, err := io.WriteString(r.writer, "<![CDATA["); err != nil {
+			return err
+		}
+		if _, err := io.Copy(r.writer, transform.NewReader(stream, transform.Chain(xmlCharSanitizer{}, cdataEscaper{}))); err != nil {
+			return err
+		}
+		_, err := io.WriteString(r.writer, "]]>\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(r.writer, "</document_content>\n</document>\n")
+	return err
+}
+
+func (r *xmlRenderer) finish() error {
+	_, err := io.WriteString(r.writer, "</documents>\n")
+	return err
+}
+
+// xmlText makes content safe to embed as XML character data.
+// Characters not allowed in XML are replaced with U+FFFD. Content containing
+// markup characters is wrapped in CDATA sections, splitting any "]]>" so that
+// it can't terminate the section early. Plain content is kept as-is for readability.
+func xmlText(content []byte) string {
+	text := sanitizeXMLChars(content)
+	if !strings.ContainsAny(text, "<&") && !strings.Contains(text, "]]>") {
+		return text
+	}
+
+	// Keep the trailing newline outside of the CDATA section
+	suffix := ""
+	if strings.HasSuffix(text, "\n") {
+		text = text[:len(text)-1]
+		suffix = "\n"
+	}
+
+	return "<![CDATA[" + strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>") + "]]>" + suffix
+}
+
+// sanitizeXMLChars replaces invalid UTF-8 and characters outside the XML Char range with U+FFFD.
+func sanitizeXMLChars(content []byte) string {
+	var builder strings.Builder
+	builder.Grow(len(content))
+
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if (r == utf8.RuneError && size <= 1) || !isXMLChar(r) {
+			builder.WriteRune(utf8.RuneError)
+		} else {
+			builder.WriteRune(r)
+		}
+		content = content[size:]
+	}
+
+	return builder.String()
+}
+
+// isXMLChar reports whether r is in the XML 1.0 Char production.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+func TestFormatter_XML(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	file1 := filepath.Join(tmpDir, "a.txt")
+	file2 := filepath.Join(tmpDir, "b.txt")
+	if err := os.WriteFile(file1, []byte("Content 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file1: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte("Content 2"), 0644); err != nil {
+		t.Fatalf("Failed to create file2: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: file1, RelPath: "a.txt", IsDir: false},
+		{Path: file2, RelPath: "b.txt", IsDir: false},
+	}
+	root := tree.Build(entries, "project")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Format: FormatXML})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	expected := `<documents>
+<directory_tree>
+project
+├── a.txt
+└── b.txt
+</directory_tree>
+<document index="1">
+<source>a.txt</source>
+<document_content>
+Content 1
+</document_content>
+</document>
+<document index="2">
+<source>b.txt</source>
+<document_content>
+Content 2
+</document_content>
+</document>
+</documents>
+`
+
+	if buf.String() != expected {
+		t.Errorf("Output mismatch.\nExpected:\n%s\n\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_XMLEscaping(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Content that would corrupt the document if embedded as-is
+	content := "if a < b && c > d {\n\tx := arr[arr[0]]>0\n}\n<![CDATA[ nested ]]>\n\x01end\n"
+	filePath := filepath.Join(tmpDir, "a&b.go")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	entries := []scanner.FileEntry{
+		{Path: filePath, RelPath: "a&b.go", IsDir: false},
+	}
+	root := tree.Build(entries, "")
+
+	var buf bytes.Buffer
+	formatter := NewFormatterWithOptions(&buf, Options{Format: FormatXML, BinaryMode: BinaryInclude})
+	if err := formatter.Format(root, entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// The output must be well-formed and round-trip the content
+	var documents struct {
+		Tree      string `xml:"directory_tree"`
+		Documents []struct {
+			Index   int    `xml:"index,attr"`
+			Source  string `xml:"source"`
+			Content string `xml:"document_content"`
+		} `xml:"document"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &documents); err != nil {
+		t.Fatalf("Output is not well-formed XML: %v\n%s", err, buf.String())
+	}
+
+	if len(documents.Documents) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(documents.Documents))
+	}
+	doc := documents.Documents[0]
+	if doc.Index != 1 {
+		t.Errorf("Index = %d, want 1", doc.Index)
+	}
+	if doc.Source != "a&b.go" {
+		t.Errorf("Source = %q, want %q", doc.Source, "a&b.go")
+	}
+
+	// Control character is replaced since it isn't allowed in XML
+	expectedContent := "\n" + "if a < b && c > d {\n\tx := arr[arr[0]]>0\n}\n<![CDATA[ nested ]]>\n�end\n"
+	if doc.Content != expectedContent {
+		t.Errorf("Content = %q, want %q", doc.Content, expectedContent)
+	}
+}
+
+func TestXMLText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"plain text", "hello\n", "hello\n"},
+		{"greater than only", "a > b\n", "a > b\n"},
+		{"markup", "<b>\n", "<![CDATA[<b>]]>\n"},
+		{"cdata end", "x]]>y", "<![CDATA[x]]]]><![CDATA[>y]]>"},
+		{"invalid utf-8", "a\xffb", "a�b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xmlText([]byte(tt.content)); got != tt.expected {
+				t.Errorf("xmlText(%q) = %q, want %q", tt.content, got, tt.expected)
+			}
+		})
+	}
+}
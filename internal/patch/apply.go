@@ -0,0 +1,341 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/sniff"
+	"github.com/onozaty/treecat/internal/unpack"
+	"golang.org/x/text/transform"
+)
+
+// Operation is what a file diff does to its file.
+type Operation int
+
+const (
+	// OpModify changes the content of an existing file.
+	OpModify Operation = iota
+	// OpCreate creates a new file.
+	OpCreate
+	// OpDelete deletes a file.
+	OpDelete
+	// OpRename moves a file, possibly changing its content.
+	OpRename
+)
+
+// String returns the name of the operation.
+func (op Operation) String() string {
+	switch op {
+	case OpCreate:
+		return "create"
+	case OpDelete:
+		return "delete"
+	case OpRename:
+		return "rename"
+	default:
+		return "modify"
+	}
+}
+
+// FileResult reports how a file diff was applied.
+type FileResult struct {
+	Diff  *FileDiff
+	Op    Operation
+	Hunks []HunkResult
+	Err   error // Why the diff could not be applied to the file at all
+}
+
+// Failed returns the number of hunks that were not applied. A file that could
+// not be patched at all counts all its hunks (at least one).
+func (r *FileResult) Failed() int {
+	if r.Err != nil {
+		return max(len(r.Diff.Hunks), 1)
+	}
+	failed := 0
+	for _, hunk := range r.Hunks {
+		if !hunk.Applied {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Options configures how diffs are applied.
+type Options struct {
+	// Fuzz is the maximum number of context lines that may be ignored at each
+	// end of a hunk to find where it applies.
+	Fuzz int
+
+	// The encoding of each file is selected like for the output of treecat:
+	// a byte order mark takes precedence over EncodingRules, and with
+	// DetectEncoding, the encoding of an existing file matching no rule is
+	// detected from its leading bytes. Files are decoded to UTF-8 before
+	// hunks are matched, so that diffs written against the output apply,
+	// and encoded back when written.
+	EncodingRules  *encoding.Rules
+	DetectEncoding bool
+}
+
+// Result is the outcome of applying diffs to a directory. Nothing is written
+// until Write is called.
+type Result struct {
+	Files []*FileResult
+
+	options Options
+	files   map[string]*fileState // Files touched by the diffs, by path
+	order   []string              // Paths in the order they were first touched
+}
+
+// fileState is the content of a file as changed by the diffs applied so far.
+type fileState struct {
+	target    string
+	exists    bool // Whether the file exists on disk
+	mode      os.FileMode
+	content   []byte             // Content decoded to UTF-8
+	converter encoding.Converter // Encoding of the file on disk (nil for UTF-8)
+	deleted   bool
+	changed   bool
+}
+
+// present returns true if the file exists after the diffs applied so far.
+func (s *fileState) present() bool {
+	return (s.exists || s.changed) && !s.deleted
+}
+
+// Failed returns the total number of hunks that were not applied.
+func (r *Result) Failed() int {
+	failed := 0
+	for _, file := range r.Files {
+		failed += file.Failed()
+	}
+	return failed
+}
+
+// Apply applies the diffs to the files under dir in memory, in order, so a
+// file can be changed by several diffs. Hunks and files that can't be applied
+// are reported in the result and leave their file unchanged.
+func Apply(diffs []*FileDiff, dir string, options Options) *Result {
+	result := &Result{options: options, files: make(map[string]*fileState)}
+	for _, diff := range diffs {
+		result.Files = append(result.Files, result.apply(diff, dir))
+	}
+	return result
+}
+
+// apply applies a file diff to the in-memory state.
+func (r *Result) apply(diff *FileDiff, dir string) *FileResult {
+	fileResult := &FileResult{Diff: diff}
+	switch {
+	case diff.IsNew():
+		fileResult.Op = OpCreate
+	case diff.IsDelete():
+		fileResult.Op = OpDelete
+	case diff.IsRename():
+		fileResult.Op = OpRename
+	}
+
+	if diff.Binary {
+		fileResult.Err = fmt.Errorf("binary diffs are not supported")
+		return fileResult
+	}
+
+	// Load the source of the diff
+	var source *fileState
+	if !diff.IsNew() {
+		state, err := r.load(dir, diff.OldPath)
+		if err != nil {
+			fileResult.Err = err
+			return fileResult
+		}
+		if !state.present() {
+			fileResult.Err = fmt.Errorf("file not found")
+			return fileResult
+		}
+		source = state
+	}
+
+	// Check the destination of new and renamed files
+	var dest *fileState
+	if diff.IsNew() || diff.IsRename() {
+		state, err := r.load(dir, diff.NewPath)
+		if err != nil {
+			fileResult.Err = err
+			return fileResult
+		}
+		if state.present() {
+			fileResult.Err = fmt.Errorf("file already exists: %s", diff.NewPath)
+			return fileResult
+		}
+		dest = state
+	}
+
+	var content []byte
+	if source != nil {
+		content = source.content
+	}
+	content, fileResult.Hunks = applyHunks(content, diff.Hunks, r.options.Fuzz)
+	if fileResult.Failed() > 0 {
+		// Keep successful hunks of modified files only, since a new, deleted
+		// or renamed file that is missing some hunks would be misleading
+		if fileResult.Op != OpModify {
+			return fileResult
+		}
+	}
+	if diff.IsDelete() && len(splitFile(content).lines) > 0 {
+		fileResult.Err = fmt.Errorf("file has content not removed by the diff")
+		return fileResult
+	}
+
+	// Renamed files keep their encoding
+	var converter encoding.Converter
+	if source != nil {
+		converter = source.converter
+	} else {
+		converter = dest.converter
+	}
+	if !diff.IsDelete() {
+		if _, err := encode(content, converter); err != nil {
+			fileResult.Err = err
+			return fileResult
+		}
+	}
+
+	switch fileResult.Op {
+	case OpModify:
+		source.content, source.changed = content, true
+	case OpCreate:
+		dest.content, dest.deleted, dest.changed = content, false, true
+		dest.mode = 0644
+	case OpDelete:
+		source.content, source.deleted, source.changed = nil, true, true
+	case OpRename:
+		dest.content, dest.deleted, dest.changed = content, false, true
+		dest.mode, dest.converter = source.mode, source.converter
+		source.content, source.deleted, source.changed = nil, true, true
+	}
+	return fileResult
+}
+
+// load returns the state of a file, reading it on first use.
+func (r *Result) load(dir, path string) (*fileState, error) {
+	if state, ok := r.files[path]; ok {
+		return state, nil
+	}
+
+	target, exists, err := unpack.ResolveTarget(dir, path)
+	if err != nil {
+		return nil, err
+	}
+	state := &fileState{target: target, exists: exists}
+	if exists {
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		content, err := os.ReadFile(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		state.content, state.mode = content, info.Mode().Perm()
+	}
+
+	state.converter = r.converterFor(path, state.content)
+	if state.converter != nil {
+		if state.content, err = decode(state.content, state.converter); err != nil {
+			return nil, err
+		}
+	}
+
+	r.files[path] = state
+	r.order = append(r.order, path)
+	return state, nil
+}
+
+// converterFor selects the encoding of a file from its path and content
+// (empty for a new file), or returns nil for UTF-8.
+func (r *Result) converterFor(path string, content []byte) encoding.Converter {
+	// Detect and DetectBOM only return encodings supported by NewConverter
+	if name := encoding.DetectBOM(content); name != "" {
+		converter, _ := encoding.NewConverter(name)
+		return converter
+	}
+
+	var converter encoding.Converter
+	specified := false
+	if r.options.EncodingRules != nil {
+		converter, specified = r.options.EncodingRules.Match(path)
+	}
+	if specified || !r.options.DetectEncoding || len(content) == 0 {
+		return converter
+	}
+
+	converter, _ = encoding.NewConverter(encoding.Detect(content[:min(len(content), sniff.SampleSize+1)]))
+	return converter
+}
+
+// decode decodes content to UTF-8. It fails if the content can't be encoded
+// back to the same bytes (e.g., it has invalid byte sequences), since writing
+// it would change the parts of the file not touched by the diff.
+func decode(content []byte, converter encoding.Converter) ([]byte, error) {
+	decoded, err := converter.ConvertToUTF8(content)
+	if err == nil {
+		var encoded []byte
+		encoded, _, err = transform.Bytes(converter.NewEncoder(), decoded)
+		if err == nil && bytes.Equal(encoded, content) {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf("file can't be decoded as %s without changing it", converter.Name())
+}
+
+// encode encodes UTF-8 content to the encoding of the file (nil for UTF-8).
+// It fails if the content has characters the encoding can't represent.
+func encode(content []byte, converter encoding.Converter) ([]byte, error) {
+	if converter == nil {
+		return content, nil
+	}
+	encoded, _, err := transform.Bytes(converter.NewEncoder(), content)
+	if err == nil {
+		var decoded []byte
+		decoded, err = converter.ConvertToUTF8(encoded)
+		if err == nil && bytes.Equal(decoded, content) {
+			return encoded, nil
+		}
+	}
+	return nil, fmt.Errorf("content has characters that can't be encoded in %s", converter.Name())
+}
+
+// Write writes the changed files to disk, including the successful hunks of
+// files where other hunks failed.
+func (r *Result) Write() error {
+	for _, path := range r.order {
+		state := r.files[path]
+		if !state.changed {
+			continue
+		}
+
+		if state.deleted {
+			if state.exists {
+				if err := os.Remove(state.target); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", path, err)
+				}
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(state.target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		content, err := encode(state.content, state.converter)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := os.WriteFile(state.target, content, state.mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
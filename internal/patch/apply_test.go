@@ -0,0 +1,312 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/main.go": "package main\n\nfunc main() {}\n",
+		"old.txt":     "old\n",
+		"a.txt":       "a\nb\n",
+	})
+
+	diffs, err := Parse([]byte(`diff --git a/src/main.go b/src/main.go
+--- a/src/main.go
++++ b/src/main.go
+@@ -1,3 +1,3 @@
+ package main
+
+-func main() {}
++func main() { run() }
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-old
+diff --git a/new/file.txt b/new/file.txt
+new file mode 100644
+--- /dev/null
++++ b/new/file.txt
+@@ -0,0 +1 @@
++new
+diff --git a/a.txt b/b.txt
+similarity index 50%
+rename from a.txt
+rename to b.txt
+--- a/a.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
+`), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	result := Apply(diffs, dir, Options{Fuzz: 2})
+	if result.Failed() != 0 {
+		for _, file := range result.Files {
+			t.Logf("%s %s: %v %+v", file.Op, file.Diff.Path(), file.Err, file.Hunks)
+		}
+		t.Fatalf("Expected all hunks to apply, %d failed", result.Failed())
+	}
+
+	expectedOps := []Operation{OpModify, OpDelete, OpCreate, OpRename}
+	for i, file := range result.Files {
+		if file.Op != expectedOps[i] {
+			t.Errorf("File %d: expected %v, got %v", i, expectedOps[i], file.Op)
+		}
+	}
+
+	// Nothing is written before Write
+	if readFile(t, dir, "src/main.go") != "package main\n\nfunc main() {}\n" {
+		t.Error("Expected files to be unchanged before Write")
+	}
+
+	if err := result.Write(); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if content := readFile(t, dir, "src/main.go"); content != "package main\n\nfunc main() { run() }\n" {
+		t.Errorf("Unexpected main.go: %q", content)
+	}
+	if content := readFile(t, dir, "new/file.txt"); content != "new\n" {
+		t.Errorf("Unexpected new/file.txt: %q", content)
+	}
+	if content := readFile(t, dir, "b.txt"); content != "a\nB\n" {
+		t.Errorf("Unexpected b.txt: %q", content)
+	}
+	for _, name := range []string{"old.txt", "a.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+}
+
+func TestApply_SameFileTwice(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "1\n2\n3\n"})
+
+	diffs, err := Parse([]byte("--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-1\n+one\n"+
+		"--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n one\n-2\n+two\n"), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	result := Apply(diffs, dir, Options{Fuzz: 2})
+	if result.Failed() != 0 {
+		t.Fatalf("Expected all hunks to apply, %d failed", result.Failed())
+	}
+	if err := result.Write(); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if content := readFile(t, dir, "a.txt"); content != "one\ntwo\n3\n" {
+		t.Errorf("Unexpected a.txt: %q", content)
+	}
+}
+
+func TestApply_Failures(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":       "a\n",
+		"exists.txt":  "x\n",
+		"partial.txt": "1\n2\n",
+	})
+
+	diffs, err := Parse([]byte(`--- missing.txt
++++ missing.txt
+@@ -1 +1 @@
+-a
++b
+--- /dev/null
++++ exists.txt
+@@ -0,0 +1 @@
++x
+--- ../escape.txt
++++ ../escape.txt
+@@ -1 +1 @@
+-a
++b
+--- partial.txt
++++ partial.txt
+@@ -1 +1 @@
+-1
++one
+@@ -2 +2 @@
+-wrong
++two
+--- a.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-other
+`), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	result := Apply(diffs, dir, Options{Fuzz: 2})
+	files := result.Files
+	if files[0].Err == nil || files[0].Err.Error() != "file not found" {
+		t.Errorf("Expected file not found, got %v", files[0].Err)
+	}
+	if files[1].Err == nil {
+		t.Error("Expected error for existing file")
+	}
+	if files[2].Err == nil {
+		t.Error("Expected error for path escaping the directory")
+	}
+	if files[3].Err != nil || !files[3].Hunks[0].Applied || files[3].Hunks[1].Applied {
+		t.Errorf("Expected only the first hunk of partial.txt to apply, got %+v", files[3].Hunks)
+	}
+	if files[4].Failed() != 1 {
+		t.Error("Expected deletion with different content to fail")
+	}
+	if result.Failed() != 5 {
+		t.Errorf("Expected 5 failed hunks, got %d", result.Failed())
+	}
+
+	// Write keeps the hunks that applied
+	if err := result.Write(); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if content := readFile(t, dir, "partial.txt"); content != "one\n2\n" {
+		t.Errorf("Unexpected partial.txt: %q", content)
+	}
+	if content := readFile(t, dir, "a.txt"); content != "a\n" {
+		t.Errorf("Expected a.txt to be kept, got %q", content)
+	}
+}
+
+func TestApply_Encodings(t *testing.T) {
+	dir := t.TempDir()
+
+	sjis := func(s string) string {
+		encoded, err := japanese.ShiftJIS.NewEncoder().String(s)
+		if err != nil {
+			t.Fatalf("Failed to encode Shift_JIS: %v", err)
+		}
+		return encoded
+	}
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("こんにちは\r\n世界\r\n")
+	if err != nil {
+		t.Fatalf("Failed to encode UTF-16: %v", err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"legacy/a.txt":     sjis("こんにちは\n世界\n"),
+		"legacy/emoji.txt": sjis("こんにちは\n"),
+		"legacy/bad.txt":   "\x82\xa0\xff\n",
+		"script.ps1":       utf16,
+		"detected.txt":     sjis("日本語のテキストです。\n"),
+	})
+
+	// Diffs written against the UTF-8 output of treecat
+	diffs, err := Parse([]byte(`--- legacy/a.txt
++++ legacy/a.txt
+@@ -1,2 +1,2 @@
+ こんにちは
+-世界
++皆さん
+--- legacy/emoji.txt
++++ legacy/emoji.txt
+@@ -1 +1 @@
+-こんにちは
++こんにちは😀
+--- legacy/bad.txt
++++ legacy/bad.txt
+@@ -1 +1 @@
+-あ
++い
+--- script.ps1
++++ script.ps1
+@@ -1,2 +1,2 @@
+-こんにちは
++さようなら
+ 世界
+--- /dev/null
++++ legacy/new.txt
+@@ -0,0 +1 @@
++新規
+--- detected.txt
++++ detected.txt
+@@ -1 +1 @@
+-日本語のテキストです。
++日本語の文章です。
+`), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	rules, err := encoding.ParseRules("legacy/**:shift_jis", "")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	result := Apply(diffs, dir, Options{Fuzz: 2, EncodingRules: rules, DetectEncoding: true})
+
+	expectedErrors := []string{
+		"",
+		"content has characters that can't be encoded in shift_jis",
+		"file can't be decoded as shift_jis without changing it",
+		"",
+		"",
+		"",
+	}
+	for i, file := range result.Files {
+		message := ""
+		if file.Err != nil {
+			message = file.Err.Error()
+		}
+		if message != expectedErrors[i] {
+			t.Errorf("%s: expected error %q, got %q (hunks %+v)", file.Diff.Path(), expectedErrors[i], message, file.Hunks)
+		}
+	}
+
+	if err := result.Write(); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	expectedUTF16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("さようなら\r\n世界\r\n")
+	expected := map[string]string{
+		"legacy/a.txt":     sjis("こんにちは\n皆さん\n"),
+		"legacy/emoji.txt": sjis("こんにちは\n"),
+		"legacy/new.txt":   sjis("新規\n"),
+		"script.ps1":       expectedUTF16,
+		"detected.txt":     sjis("日本語の文章です。\n"),
+	}
+	for name, content := range expected {
+		if actual := readFile(t, dir, name); actual != content {
+			t.Errorf("Unexpected %s: %q", name, actual)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package patch
+
+import (
+	"bytes"
+	"strings"
+)
+
+// HunkResult reports how a hunk was applied.
+type HunkResult struct {
+	Hunk       *Hunk
+	Applied    bool
+	Line       int    // Line of the original file where the hunk was applied (1-based)
+	Offset     int    // Lines between the position in the header and where the hunk was applied
+	Fuzz       int    // Context lines ignored at each end of the hunk to find a match
+	Whitespace bool   // Whether whitespace differences were ignored to find a match
+	Reason     string // Why the hunk could not be applied
+}
+
+// utf8BOM is kept at the beginning of files that have one.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// fileLines is the content of a file as lines, keeping its BOM and line endings.
+type fileLines struct {
+	bom          bool
+	lines        []string // Lines without "\n" (a "\r" of CRLF line endings is kept)
+	finalNewline bool     // Whether the last line ends with a newline
+	crlf         bool     // Whether added lines get CRLF line endings
+}
+
+// splitFile splits content into lines.
+func splitFile(content []byte) *fileLines {
+	f := &fileLines{finalNewline: true}
+	if bytes.HasPrefix(content, utf8BOM) {
+		f.bom = true
+		content = content[len(utf8BOM):]
+	}
+	if len(content) == 0 {
+		return f
+	}
+
+	// Use the line ending of the majority of lines for added lines
+	crlf := bytes.Count(content, []byte("\r\n"))
+	f.crlf = crlf > 0 && crlf*2 >= bytes.Count(content, []byte("\n"))
+
+	text := string(content)
+	f.finalNewline = strings.HasSuffix(text, "\n")
+	f.lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	return f
+}
+
+// bytes joins the lines back into content.
+func (f *fileLines) bytes() []byte {
+	var buf bytes.Buffer
+	if f.bom {
+		buf.Write(utf8BOM)
+	}
+	for i, line := range f.lines {
+		buf.WriteString(line)
+		if i < len(f.lines)-1 || f.finalNewline {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// newLine returns an added line with the line ending of the file.
+func (f *fileLines) newLine(text string) string {
+	if f.crlf {
+		return text + "\r"
+	}
+	return text
+}
+
+// matchMode is how lines are compared when searching for a hunk.
+type matchMode int
+
+const (
+	matchExact matchMode = iota
+	matchWhitespace
+)
+
+// equal compares a line of the file with a line of the hunk.
+func (m matchMode) equal(fileLine, hunkLine string) bool {
+	fileLine = strings.TrimSuffix(fileLine, "\r")
+	if m == matchExact {
+		return fileLine == hunkLine
+	}
+	return strings.Join(strings.Fields(fileLine), " ") == strings.Join(strings.Fields(hunkLine), " ")
+}
+
+// applyHunks applies the hunks to content in order. Each hunk is searched
+// for near the position in its header, first exactly and then ignoring
+// whitespace differences, and then ignoring up to fuzz context lines at each
+// end. Hunks that can't be found are skipped and reported as not applied.
+func applyHunks(content []byte, hunks []Hunk, fuzz int) ([]byte, []HunkResult) {
+	file := splitFile(content)
+	results := make([]HunkResult, len(hunks))
+
+	delta := 0  // Lines added minus lines removed by the hunks applied so far
+	minPos := 0 // Hunks are applied in order and may not overlap
+	for i := range hunks {
+		hunk := &hunks[i]
+		result := HunkResult{Hunk: hunk, Reason: "context not found"}
+
+		lines, pos, usedFuzz, mode, ok := findHunk(file, hunk, delta, minPos, fuzz)
+		if ok {
+			removed, added := replace(file, lines, pos)
+			result = HunkResult{
+				Hunk:       hunk,
+				Applied:    true,
+				Line:       pos - delta + 1,
+				Offset:     pos - expectedPos(hunk, delta, usedFuzz),
+				Fuzz:       usedFuzz,
+				Whitespace: mode == matchWhitespace,
+			}
+			delta += added - removed
+			minPos = pos + added
+		}
+		results[i] = result
+	}
+
+	return file.bytes(), results
+}
+
+// expectedPos returns the index where the hunk should start according to its
+// header, after skipping fuzz leading context lines.
+func expectedPos(hunk *Hunk, delta, fuzz int) int {
+	pos := hunk.OldStart - 1
+	if hunk.OldLines == 0 {
+		// The start of an insertion is the line after which it is inserted
+		pos = hunk.OldStart
+	}
+	return max(pos, 0) + delta + fuzz
+}
+
+// findHunk searches for the lines of the hunk in the file and returns the
+// lines to replace, where they start, the fuzz and the match mode used.
+func findHunk(file *fileLines, hunk *Hunk, delta, minPos, maxFuzz int) ([]Line, int, int, matchMode, bool) {
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		lines, ok := trimContext(hunk.Lines, fuzz)
+		if !ok {
+			break
+		}
+		for _, mode := range []matchMode{matchExact, matchWhitespace} {
+			if pos, ok := search(file, lines, expectedPos(hunk, delta, fuzz), minPos, mode); ok {
+				return lines, pos, fuzz, mode, true
+			}
+		}
+	}
+	return nil, 0, 0, matchExact, false
+}
+
+// trimContext removes up to n context lines from each end of the hunk lines.
+// Returns false if nothing would be left to match.
+func trimContext(lines []Line, n int) ([]Line, bool) {
+	if n == 0 {
+		return lines, true
+	}
+	trimmed := lines
+	for i := 0; i < n && len(trimmed) > 0 && trimmed[0].Kind == LineContext; i++ {
+		trimmed = trimmed[1:]
+	}
+	for i := 0; i < n && len(trimmed) > 0 && trimmed[len(trimmed)-1].Kind == LineContext; i++ {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	if len(trimmed) == len(lines) {
+		// Nothing to trim, so no new match is possible
+		return nil, false
+	}
+	for _, line := range trimmed {
+		if line.Kind != LineAdd {
+			return trimmed, true
+		}
+	}
+	return nil, false
+}
+
+// search returns the position nearest to hint, at or after minPos, where
+// the old lines of the hunk match the file.
+func search(file *fileLines, lines []Line, hint, minPos int, mode matchMode) (int, bool) {
+	var old []string
+	for _, line := range lines {
+		if line.Kind != LineAdd {
+			old = append(old, line.Text)
+		}
+	}
+
+	maxPos := len(file.lines) - len(old)
+	if maxPos < minPos {
+		return 0, false
+	}
+	if len(old) == 0 {
+		// Pure insertion: nothing to match, so trust the header
+		return min(max(hint, minPos), maxPos), true
+	}
+
+	matches := func(pos int) bool {
+		for i, text := range old {
+			if !mode.equal(file.lines[pos+i], text) {
+				return false
+			}
+		}
+		return true
+	}
+
+	hint = min(max(hint, minPos), maxPos)
+	for d := 0; hint-d >= minPos || hint+d <= maxPos; d++ {
+		if hint-d >= minPos && matches(hint-d) {
+			return hint - d, true
+		}
+		if d > 0 && hint+d <= maxPos && matches(hint+d) {
+			return hint + d, true
+		}
+	}
+	return 0, false
+}
+
+// replace replaces the old lines of the hunk at pos with its new lines and
+// returns the number of lines removed and added. Context lines keep the text
+// of the file, so whitespace the hunk ignored is preserved.
+func replace(file *fileLines, lines []Line, pos int) (int, int) {
+	var replacement []string
+	removed := 0
+	noNewline := false
+	for _, line := range lines {
+		switch line.Kind {
+		case LineContext:
+			replacement = append(replacement, file.lines[pos+removed])
+			removed++
+		case LineDelete:
+			removed++
+		case LineAdd:
+			replacement = append(replacement, file.newLine(line.Text))
+		}
+		if line.NoNewline {
+			noNewline = true
+		}
+	}
+
+	// "\ No newline at end of file" decides the final newline when the hunk
+	// reaches the end of the file
+	if noNewline && pos+removed == len(file.lines) {
+		file.finalNewline = true
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i].Kind != LineDelete {
+				file.finalNewline = !lines[i].NoNewline
+				break
+			}
+		}
+	}
+
+	file.lines = append(file.lines[:pos], append(replacement, file.lines[pos+removed:]...)...)
+	return removed, len(replacement)
+}
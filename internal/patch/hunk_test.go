@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"testing"
+)
+
+// parseHunks parses the hunks of a single file diff.
+func parseHunks(t *testing.T, hunks string) []Hunk {
+	t.Helper()
+	diffs, err := Parse([]byte("--- a.txt\n+++ a.txt\n"+hunks), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return diffs[0].Hunks
+}
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		hunks      string
+		expected   string
+		line       int
+		offset     int
+		fuzz       int
+		whitespace bool
+	}{
+		{
+			name:     "exact",
+			content:  "a\nb\nc\n",
+			hunks:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			expected: "a\nB\nc\n",
+			line:     1,
+		},
+		{
+			name:     "offset",
+			content:  "x\nx\na\nb\nc\n",
+			hunks:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			expected: "x\nx\na\nB\nc\n",
+			line:     3,
+			offset:   2,
+		},
+		{
+			name:       "whitespace ignored",
+			content:    "func() {\n\treturn 1\n}\n",
+			hunks:      "@@ -1,3 +1,3 @@\n func() {\n-    return 1\n+\treturn 2\n }\n",
+			expected:   "func() {\n\treturn 2\n}\n",
+			line:       1,
+			whitespace: true,
+		},
+		{
+			name:     "fuzz",
+			content:  "a\nb\nc\nd\n",
+			hunks:    "@@ -1,4 +1,4 @@\n wrong\n b\n-c\n+C\n d\n",
+			expected: "a\nb\nC\nd\n",
+			line:     2,
+			fuzz:     1,
+		},
+		{
+			name:     "insertion",
+			content:  "a\nb\n",
+			hunks:    "@@ -1,0 +2 @@\n+inserted\n",
+			expected: "a\ninserted\nb\n",
+			line:     2,
+		},
+		{
+			name:     "crlf and bom preserved",
+			content:  "\ufeffa\r\nb\r\n",
+			hunks:    "@@ -1,2 +1,3 @@\n a\n-b\n+B\n+c\n",
+			expected: "\ufeffa\r\nB\r\nc\r\n",
+			line:     1,
+		},
+		{
+			name:     "no newline at end of file",
+			content:  "a\nb\n",
+			hunks:    "@@ -1,2 +1,2 @@\n a\n-b\n+B\n\\ No newline at end of file\n",
+			expected: "a\nB",
+			line:     1,
+		},
+		{
+			name:     "newline added at end of file",
+			content:  "a\nb",
+			hunks:    "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			expected: "a\nb\n",
+			line:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, results := applyHunks([]byte(tt.content), parseHunks(t, tt.hunks), 2)
+
+			if string(content) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(content))
+			}
+			result := results[0]
+			if !result.Applied {
+				t.Fatalf("Expected hunk to be applied: %s", result.Reason)
+			}
+			if result.Line != tt.line || result.Offset != tt.offset || result.Fuzz != tt.fuzz || result.Whitespace != tt.whitespace {
+				t.Errorf("Unexpected result: line %d, offset %d, fuzz %d, whitespace %v",
+					result.Line, result.Offset, result.Fuzz, result.Whitespace)
+			}
+		})
+	}
+}
+
+func TestApplyHunks_MultipleHunks(t *testing.T) {
+	content := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	hunks := parseHunks(t, "@@ -1,2 +1,3 @@\n 1\n+1.5\n 2\n"+
+		"@@ -5,3 +6,2 @@\n 5\n-6\n 7\n"+
+		"@@ -8,1 +8,1 @@\n-missing\n+x\n")
+
+	result, results := applyHunks([]byte(content), hunks, 2)
+
+	expected := "1\n1.5\n2\n3\n4\n5\n7\n8\n9\n"
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(result))
+	}
+	if !results[0].Applied || !results[1].Applied {
+		t.Fatal("Expected first two hunks to be applied")
+	}
+	// The second hunk is found where its header says, after the first one added a line
+	if results[1].Line != 5 || results[1].Offset != 0 {
+		t.Errorf("Unexpected second hunk result: line %d, offset %d", results[1].Line, results[1].Offset)
+	}
+	if results[2].Applied || results[2].Reason != "context not found" {
+		t.Errorf("Expected third hunk to fail, got %+v", results[2])
+	}
+}
+
+func TestApplyHunks_NoFuzz(t *testing.T) {
+	hunks := parseHunks(t, "@@ -1,4 +1,4 @@\n wrong\n b\n-c\n+C\n d\n")
+
+	content, results := applyHunks([]byte("a\nb\nc\nd\n"), hunks, 0)
+	if results[0].Applied {
+		t.Error("Expected hunk not to be applied without fuzz")
+	}
+	if string(content) != "a\nb\nc\nd\n" {
+		t.Errorf("Expected content to be unchanged, got %q", string(content))
+	}
+}
@@ -0,0 +1,345 @@
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineKind is the kind of a hunk line.
+type LineKind int
+
+const (
+	// LineContext is a line kept unchanged (" ").
+	LineContext LineKind = iota
+	// LineDelete is a line removed from the file ("-").
+	LineDelete
+	// LineAdd is a line added to the file ("+").
+	LineAdd
+)
+
+// Line is a line of a hunk.
+type Line struct {
+	Kind      LineKind
+	Text      string // Line without the prefix and the newline
+	NoNewline bool   // Followed by "\ No newline at end of file"
+}
+
+// Hunk is a "@@ -a,b +c,d @@" section of a file diff.
+type Hunk struct {
+	OldStart int // First line in the original file (1-based, 0 if unknown)
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the "@@ -a,b +c,d @@" header of the hunk.
+func (h *Hunk) Header() string {
+	if h.OldStart == 0 && h.NewStart == 0 {
+		return "@@ @@"
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// FileDiff is the diff of a single file.
+type FileDiff struct {
+	OldPath string // Empty for a new file
+	NewPath string // Empty for a deleted file
+	Hunks   []Hunk
+	Binary  bool // Binary diffs can't be applied
+
+	exactPaths bool // Paths come from "rename from/to" lines, which have no prefixes
+}
+
+// IsNew returns true if the diff creates a file.
+func (d *FileDiff) IsNew() bool {
+	return d.OldPath == ""
+}
+
+// IsDelete returns true if the diff deletes a file.
+func (d *FileDiff) IsDelete() bool {
+	return d.NewPath == ""
+}
+
+// IsRename returns true if the diff renames a file.
+func (d *FileDiff) IsRename() bool {
+	return !d.IsNew() && !d.IsDelete() && d.OldPath != d.NewPath
+}
+
+// Path returns the path the diff applies to (the new path unless the file is deleted).
+func (d *FileDiff) Path() string {
+	if d.IsDelete() {
+		return d.OldPath
+	}
+	return d.NewPath
+}
+
+// hunkHeaderPattern matches "@@ -a,b +c,d @@" (the counts are optional).
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// devNull is the path used for the missing side of new and deleted files.
+const devNull = "/dev/null"
+
+// Parse parses unified diffs, as produced by "diff -u" or "git diff".
+// Text around the diffs (such as explanations or code fences in an LLM
+// answer) is ignored.
+//
+// strip is the number of leading path components to remove from the paths,
+// like "patch -p". If strip is negative, the "a/" and "b/" prefixes of git
+// diffs are removed.
+//
+// A hunk ends when the line counts in its header are reached, so that text
+// following the diff (such as a "- " list item after a blank line) is not
+// taken as part of it. Since hand-edited and generated diffs often get the
+// counts wrong, a hunk without counts ("@@ @@"), or whose lines continue right
+// after the counts are reached, continues as long as its lines start with " ",
+// "-" or "+" (or are empty, for context lines whose trailing space was lost).
+func Parse(data []byte, strip int) ([]*FileDiff, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	var diffs []*FileDiff
+	var current *FileDiff
+	gitHeader := false // current was started by "diff --git" and has no "---" yet
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath := parseGitHeader(strings.TrimPrefix(line, "diff --git "))
+			current = &FileDiff{OldPath: oldPath, NewPath: newPath}
+			diffs = append(diffs, current)
+			gitHeader = true
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath := parsePath(strings.TrimPrefix(line, "--- "))
+			newPath := parsePath(strings.TrimPrefix(lines[i+1], "+++ "))
+			if current == nil || !gitHeader {
+				current = &FileDiff{}
+				diffs = append(diffs, current)
+			}
+			current.OldPath, current.NewPath = oldPath, newPath
+			current.exactPaths = false
+			gitHeader = false
+			i++
+
+		case gitHeader && strings.HasPrefix(line, "new file mode"):
+			current.OldPath = devNull
+		case gitHeader && strings.HasPrefix(line, "deleted file mode"):
+			current.NewPath = devNull
+		case gitHeader && strings.HasPrefix(line, "rename from "):
+			current.OldPath = parsePath(strings.TrimPrefix(line, "rename from "))
+			current.exactPaths = true
+		case gitHeader && strings.HasPrefix(line, "rename to "):
+			current.NewPath = parsePath(strings.TrimPrefix(line, "rename to "))
+			current.exactPaths = true
+		case current != nil && (strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch"):
+			current.Binary = true
+
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			gitHeader = false
+			i = next - 1
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil, fmt.Errorf("no diffs found")
+	}
+
+	for _, diff := range diffs {
+		if err := diff.stripPaths(strip); err != nil {
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
+
+// parseGitHeader parses the paths of a "diff --git a/path b/path" line.
+func parseGitHeader(paths string) (string, string) {
+	if strings.HasPrefix(paths, `"`) {
+		if oldPath, rest, ok := cutQuoted(paths); ok {
+			return oldPath, parsePath(strings.TrimSpace(rest))
+		}
+	}
+	// Both paths are the same unless the file is renamed, in which case
+	// the "rename from/to" lines give the exact paths
+	if i := strings.LastIndex(paths, " b/"); i >= 0 {
+		return paths[:i], parsePath(paths[i+1:])
+	}
+	oldPath, newPath, _ := strings.Cut(paths, " ")
+	return oldPath, parsePath(newPath)
+}
+
+// parsePath parses the path of a "---" or "+++" line, removing a trailing
+// timestamp and unquoting C-style quoted paths.
+func parsePath(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, _, ok := cutQuoted(path); ok {
+			return unquoted
+		}
+	}
+	if i := strings.Index(path, "\t"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimSpace(path)
+}
+
+// cutQuoted unquotes the quoted string at the beginning of s and returns the rest.
+func cutQuoted(s string) (string, string, bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", false
+			}
+			return unquoted, s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns it along with
+// the index of the first line after it. A header without line numbers ("@@ @@")
+// is accepted, leaving the position of the hunk unknown.
+func parseHunk(lines []string, start int) (Hunk, int, error) {
+	var hunk Hunk
+	counted := false // Whether the hunk ends at the counts of the header
+	if match := hunkHeaderPattern.FindStringSubmatch(lines[start]); match != nil {
+		hunk = Hunk{
+			OldStart: atoi(match[1], 0),
+			OldLines: atoi(match[2], 1),
+			NewStart: atoi(match[3], 0),
+			NewLines: atoi(match[4], 1),
+		}
+		counted = true
+	}
+
+	oldLines, newLines := 0, 0
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isHeader(lines, i) {
+			break
+		}
+
+		// Once the counts are reached, the hunk ends unless its lines continue
+		// right after them, in which case the counts are wrong
+		if counted && oldLines >= hunk.OldLines && newLines >= hunk.NewLines {
+			if line == "" || strings.IndexByte(" -+\\", line[0]) < 0 {
+				break
+			}
+			if line[0] != '\\' {
+				counted = false
+			}
+		}
+
+		if line == "" {
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineContext})
+			oldLines++
+			newLines++
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineContext, Text: line[1:]})
+			oldLines++
+			newLines++
+			continue
+		case '-':
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineDelete, Text: line[1:]})
+			oldLines++
+			continue
+		case '+':
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineAdd, Text: line[1:]})
+			newLines++
+			continue
+		case '\\':
+			// "\ No newline at end of file"
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+			continue
+		}
+		break
+	}
+	complete := counted && oldLines >= hunk.OldLines && newLines >= hunk.NewLines
+
+	// Empty lines at the end separate the hunk from what follows,
+	// unless they are context lines within the counts
+	for end := i; !complete && len(hunk.Lines) > 0 && lines[end-1] == ""; end-- {
+		hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+	}
+
+	if len(hunk.Lines) == 0 {
+		return Hunk{}, 0, fmt.Errorf("line %d: empty hunk", start+1)
+	}
+	return hunk, i, nil
+}
+
+// isHeader returns true if lines[i] starts a new hunk or file diff.
+func isHeader(lines []string, i int) bool {
+	line := lines[i]
+	return strings.HasPrefix(line, "@@") ||
+		strings.HasPrefix(line, "diff ") ||
+		(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "))
+}
+
+// atoi converts a hunk header number, returning def for an omitted count.
+func atoi(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// stripPaths removes the leading path components and clears /dev/null paths.
+func (d *FileDiff) stripPaths(strip int) error {
+	if d.exactPaths {
+		strip = 0
+	}
+
+	// Strip git's "a/" and "b/" prefixes when both sides use them
+	if strip < 0 {
+		strip = 0
+		if (d.OldPath == devNull || strings.HasPrefix(d.OldPath, "a/")) &&
+			(d.NewPath == devNull || strings.HasPrefix(d.NewPath, "b/")) {
+			strip = 1
+		}
+	}
+
+	for _, path := range []*string{&d.OldPath, &d.NewPath} {
+		if *path == devNull {
+			*path = ""
+			continue
+		}
+		stripped := *path
+		for n := 0; n < strip; n++ {
+			_, rest, ok := strings.Cut(stripped, "/")
+			if !ok {
+				return fmt.Errorf("cannot strip %d path components from %s", strip, *path)
+			}
+			stripped = rest
+		}
+		*path = stripped
+	}
+
+	if d.OldPath == "" && d.NewPath == "" {
+		return fmt.Errorf("diff without file paths")
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_GitDiff(t *testing.T) {
+	data := `Here is the change:
+
+` + "```diff" + `
+diff --git a/src/main.go b/src/main.go
+index 1234567..89abcde 100644
+--- a/src/main.go
++++ b/src/main.go
+@@ -1,3 +1,4 @@ package main
+ package main
+
++import "fmt"
+ func main() {
+@@ -10,2 +11,2 @@
+-	old()
++	new()
+ }
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-old
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+\ No newline at end of file
+diff --git a/a.txt b/b.txt
+similarity index 100%
+rename from a.txt
+rename to b.txt
+` + "```" + `
+
+Let me know if you need anything else.
+`
+
+	diffs, err := Parse([]byte(data), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diffs) != 4 {
+		t.Fatalf("Expected 4 diffs, got %d", len(diffs))
+	}
+
+	main := diffs[0]
+	if main.OldPath != "src/main.go" || main.NewPath != "src/main.go" {
+		t.Errorf("Unexpected paths: %q, %q", main.OldPath, main.NewPath)
+	}
+	if len(main.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(main.Hunks))
+	}
+	first := main.Hunks[0]
+	if first.OldStart != 1 || first.OldLines != 3 || first.NewStart != 1 || first.NewLines != 4 {
+		t.Errorf("Unexpected hunk header: %s", first.Header())
+	}
+	expectedKinds := []LineKind{LineContext, LineContext, LineAdd, LineContext}
+	if len(first.Lines) != len(expectedKinds) {
+		t.Fatalf("Expected %d lines, got %d", len(expectedKinds), len(first.Lines))
+	}
+	for i, kind := range expectedKinds {
+		if first.Lines[i].Kind != kind {
+			t.Errorf("Line %d: expected kind %v, got %v", i, kind, first.Lines[i].Kind)
+		}
+	}
+	if first.Lines[1].Text != "" {
+		t.Errorf("Expected empty context line, got %q", first.Lines[1].Text)
+	}
+	// The closing fence and the text after it are not part of the last hunk
+	if n := len(main.Hunks[1].Lines); n != 3 {
+		t.Errorf("Expected 3 lines in second hunk, got %d", n)
+	}
+
+	if !diffs[1].IsDelete() || diffs[1].Path() != "old.txt" {
+		t.Errorf("Expected deletion of old.txt, got %+v", diffs[1])
+	}
+
+	created := diffs[2]
+	if !created.IsNew() || created.Path() != "new.txt" {
+		t.Errorf("Expected creation of new.txt, got %+v", created)
+	}
+	if !created.Hunks[0].Lines[0].NoNewline {
+		t.Error("Expected no newline at end of file")
+	}
+
+	renamed := diffs[3]
+	if !renamed.IsRename() || renamed.OldPath != "a.txt" || renamed.NewPath != "b.txt" {
+		t.Errorf("Expected rename of a.txt to b.txt, got %+v", renamed)
+	}
+}
+
+func TestParse_PlainDiff(t *testing.T) {
+	data := "--- src/util.go\t2024-01-01 00:00:00\n" +
+		"+++ src/util.go\t2024-01-02 00:00:00\n" +
+		"@@ -1 +1 @@\n" +
+		"-a\n" +
+		"+b\n" +
+		"\n" +
+		"--- docs/README.md\n" +
+		"+++ docs/README.md\n" +
+		"@@ @@\n" +
+		" # Title\n" +
+		"+text\n"
+
+	diffs, err := Parse([]byte(data), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 diffs, got %d", len(diffs))
+	}
+	// Without a/ and b/ prefixes, nothing is stripped
+	if diffs[0].Path() != "src/util.go" {
+		t.Errorf("Unexpected path: %q", diffs[0].Path())
+	}
+	if n := len(diffs[0].Hunks[0].Lines); n != 2 {
+		t.Errorf("Expected trailing empty line to be dropped, got %d lines", n)
+	}
+	if diffs[1].Hunks[0].Header() != "@@ @@" {
+		t.Errorf("Expected hunk without line numbers, got %s", diffs[1].Hunks[0].Header())
+	}
+}
+
+func TestParse_HunkCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string // Lines of the hunk with their prefix
+	}{
+		{
+			"prose after the counts",
+			"--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n\n- uppercased two for consistency\n",
+			[]string{" one", "-two", "+TWO"},
+		},
+		{
+			"empty context line within the counts",
+			"--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n-one\n+ONE\n\n three\n\nDone.\n",
+			[]string{"-one", "+ONE", " ", " three"},
+		},
+		{
+			"no newline marker after the counts",
+			"--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-one\n+ONE\n\\ No newline at end of file\n",
+			[]string{"-one", "+ONE"},
+		},
+		{
+			"counts too small",
+			"--- a.txt\n+++ a.txt\n@@ -1,1 +1,1 @@\n one\n-two\n+TWO\n\n",
+			[]string{" one", "-two", "+TWO"},
+		},
+		{
+			"without counts",
+			"--- a.txt\n+++ a.txt\n@@ @@\n one\n-two\n+TWO\n\n- uppercased two\n",
+			[]string{" one", "-two", "+TWO", " ", "- uppercased two"},
+		},
+	}
+
+	prefixes := map[LineKind]string{LineContext: " ", LineDelete: "-", LineAdd: "+"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Parse([]byte(tt.data), -1)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			var lines []string
+			for _, line := range diffs[0].Hunks[0].Lines {
+				lines = append(lines, prefixes[line.Kind]+line.Text)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("Expected %q, got %q", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestParse_Strip(t *testing.T) {
+	data := "--- x/src/a.txt\n+++ y/src/a.txt\n@@ -1 +1 @@\n-a\n+b\n"
+
+	diffs, err := Parse([]byte(data), 1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diffs[0].OldPath != "src/a.txt" || diffs[0].NewPath != "src/a.txt" {
+		t.Errorf("Unexpected paths: %q, %q", diffs[0].OldPath, diffs[0].NewPath)
+	}
+
+	if _, err := Parse([]byte(data), 3); err == nil {
+		t.Error("Expected error when stripping more components than the path has")
+	}
+}
+
+func TestParse_QuotedPath(t *testing.T) {
+	data := "diff --git \"a/with space.txt\" \"b/with space.txt\"\n" +
+		"--- \"a/with space.txt\"\n" +
+		"+++ \"b/with space.txt\"\n" +
+		"@@ -1 +1 @@\n-a\n+b\n"
+
+	diffs, err := Parse([]byte(data), -1)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diffs[0].Path() != "with space.txt" {
+		t.Errorf("Unexpected path: %q", diffs[0].Path())
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no diffs", "just some text\n"},
+		{"hunk without header", "@@ -1 +1 @@\n-a\n+b\n"},
+		{"empty hunk", "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data), -1); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dirKey identifies a directory regardless of the path it is reached through.
+// Without device and inode numbers, the path with symbolic links resolved is used.
+type dirKey struct {
+	path string
+}
+
+// newDirKey returns the resolved path of the directory.
+func newDirKey(path string, info os.FileInfo) dirKey {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	return dirKey{path: resolved}
+}
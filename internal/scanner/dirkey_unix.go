@@ -0,0 +1,22 @@
+//go:build unix
+
+package scanner
+
+import (
+	"os"
+	"syscall"
+)
+
+// dirKey identifies a directory regardless of the path it is reached through.
+type dirKey struct {
+	dev uint64
+	ino uint64
+}
+
+// newDirKey returns the device and inode of the directory.
+func newDirKey(path string, info os.FileInfo) dirKey {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return dirKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}
+	}
+	return dirKey{}
+}
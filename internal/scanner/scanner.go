@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -16,12 +17,21 @@ type FileEntry struct {
 	RelPath string // Relative to scan root
 	IsDir   bool   // Whether it's a directory
 	Size    int64  // File size in bytes
+
+	LinkTarget string // Target of a followed symbolic link (empty otherwise)
+	Err        error  // Why the entry could not be scanned or read (set with KeepGoing)
 }
 
 // Scanner scans a directory and collects files.
 type Scanner struct {
 	Root   string         // Root directory (absolute path)
 	Filter filter.Filter  // Filter to apply when scanning
+
+	FollowSymlinks     bool // Descend into symbolic links to directories and read linked files
+	NoExternalSymlinks bool // Skip symbolic links pointing outside Root (with FollowSymlinks)
+	KeepGoing          bool // Record entries that can't be accessed in FileEntry.Err instead of failing
+
+	warnings []string
 }
 
 // NewScanner creates a new Scanner.
@@ -47,12 +57,27 @@ func NewScanner(root string, filter filter.Filter) (*Scanner, error) {
 	}, nil
 }
 
+// Warnings returns the symbolic links skipped by the last Scan.
+func (s *Scanner) Warnings() []string {
+	return s.warnings
+}
+
 // Scan walks the directory and returns a list of files.
 func (s *Scanner) Scan() ([]FileEntry, error) {
+	s.warnings = nil
+	if s.FollowSymlinks {
+		return s.scanFollowingSymlinks()
+	}
+
 	var entries []FileEntry
 
 	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
+			if s.KeepGoing && path != s.Root {
+				// The contents of an unreadable directory are skipped
+				entries = s.recordFailure(entries, path, d != nil && d.IsDir(), err)
+				return nil
+			}
 			// Return error immediately (don't skip)
 			return fmt.Errorf("cannot access %s: %w", path, err)
 		}
@@ -60,6 +85,10 @@ func (s *Scanner) Scan() ([]FileEntry, error) {
 		// Get file info
 		info, err := d.Info()
 		if err != nil {
+			if s.KeepGoing {
+				entries = s.recordFailure(entries, path, d.IsDir(), err)
+				return nil
+			}
 			return fmt.Errorf("cannot get info for %s: %w", path, err)
 		}
 
@@ -101,10 +130,50 @@ func (s *Scanner) Scan() ([]FileEntry, error) {
 		return nil, fmt.Errorf("failed to walk directory: %w", err)
 	}
 
-	// Sort by relative path (lexicographic order)
+	sortEntries(entries)
+
+	return entries, nil
+}
+
+// recordFailure records that path could not be accessed, marking its entry
+// if it was already added (a directory whose contents can't be read) or
+// adding an entry otherwise. Paths excluded by the filter are not recorded.
+func (s *Scanner) recordFailure(entries []FileEntry, path string, isDir bool, err error) []FileEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Path == path {
+			entries[i].Err = err
+			return entries
+		}
+	}
+
+	if s.Filter != nil && !s.Filter.ShouldInclude(path, isDir) {
+		return entries
+	}
+	relPath, relErr := filepath.Rel(s.Root, path)
+	if relErr != nil {
+		relPath = path
+	}
+	return append(entries, FileEntry{
+		Path:    path,
+		RelPath: relPath,
+		IsDir:   isDir,
+		Err:     err,
+	})
+}
+
+// ErrorReason returns a short description of an access error for display,
+// such as "permission denied", without the path.
+func ErrorReason(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// sortEntries sorts entries by relative path (lexicographic order).
+func sortEntries(entries []FileEntry) {
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].RelPath < entries[j].RelPath
 	})
-
-	return entries, nil
 }
\ No newline at end of file
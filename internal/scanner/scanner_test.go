@@ -3,6 +3,7 @@ package scanner
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/onozaty/treecat/internal/filter"
@@ -223,3 +224,53 @@ func TestScanner_NestedDirectories(t *testing.T) {
 		}
 	}
 }
+
+func TestScanner_KeepGoing(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("Permissions are not enforced on this platform or for root")
+	}
+
+	tmpDir := t.TempDir()
+	locked := filepath.Join(tmpDir, "locked")
+	if err := os.MkdirAll(locked, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(locked, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Failed to change permissions: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	scanner, err := NewScanner(tmpDir, nil)
+	if err != nil {
+		t.Fatalf("NewScanner failed: %v", err)
+	}
+
+	if _, err := scanner.Scan(); err == nil {
+		t.Error("Expected error for unreadable directory")
+	}
+
+	scanner.KeepGoing = true
+	entries, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d: %v", len(entries), entries)
+	}
+	if entries[0].RelPath != "locked" || !entries[0].IsDir || entries[0].Err == nil {
+		t.Errorf("Expected locked directory with an error, got %+v", entries[0])
+	}
+	if ErrorReason(entries[0].Err) != "permission denied" {
+		t.Errorf("Unexpected reason: %q", ErrorReason(entries[0].Err))
+	}
+	if entries[1].RelPath != "main.go" || entries[1].Err != nil {
+		t.Errorf("Expected main.go without error, got %+v", entries[1])
+	}
+}
@@ -0,0 +1,194 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// symlinkWalker walks a directory tree following symbolic links.
+type symlinkWalker struct {
+	scanner      *Scanner
+	resolvedRoot string          // Root with symbolic links resolved
+	visited      map[dirKey]bool // Directories already walked
+	pending      []subdir        // Symbolic links to directories, walked after real directories
+	entries      []FileEntry
+}
+
+// subdir is a directory to walk, possibly reached through a symbolic link.
+type subdir struct {
+	path, relPath, target string
+	info                  os.FileInfo
+}
+
+// scanFollowingSymlinks walks the directory like Scan, but descends into
+// symbolic links to directories and reports linked files with the size of
+// their target. Each directory is walked only once, identified by its device
+// and inode, so links forming a cycle (or pointing to a directory already
+// walked) are skipped with a warning, as are broken links and, with
+// NoExternalSymlinks, links pointing outside the root. Links to directories
+// are walked after the real directories, so a directory is listed at its
+// real location rather than through a link when it can be.
+func (s *Scanner) scanFollowingSymlinks() ([]FileEntry, error) {
+	resolvedRoot, err := filepath.EvalSymlinks(s.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+
+	info, err := os.Stat(s.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: cannot access %s: %w", s.Root, err)
+	}
+	if s.Filter != nil && !s.Filter.ShouldInclude(s.Root, true) {
+		return nil, nil
+	}
+
+	w := &symlinkWalker{
+		scanner:      s,
+		resolvedRoot: resolvedRoot,
+		visited:      map[dirKey]bool{newDirKey(s.Root, info): true},
+	}
+	if err := w.walk(s.Root); err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	for len(w.pending) > 0 {
+		dir := w.pending[0]
+		w.pending = w.pending[1:]
+		if err := w.addDir(dir); err != nil {
+			return nil, fmt.Errorf("failed to walk directory: %w", err)
+		}
+	}
+
+	sortEntries(w.entries)
+	return w.entries, nil
+}
+
+// walk adds the entries of dir and walks its subdirectories.
+func (w *symlinkWalker) walk(dir string) error {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if w.scanner.KeepGoing && dir != w.scanner.Root {
+			w.entries = w.scanner.recordFailure(w.entries, dir, true, err)
+			return nil
+		}
+		return fmt.Errorf("cannot access %s: %w", dir, err)
+	}
+
+	for _, d := range dirEntries {
+		path := filepath.Join(dir, d.Name())
+		relPath, err := filepath.Rel(w.scanner.Root, path)
+		if err != nil {
+			return fmt.Errorf("cannot get relative path for %s: %w", path, err)
+		}
+
+		linkTarget := ""
+		if d.Type()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				if w.scanner.KeepGoing {
+					w.entries = w.scanner.recordFailure(w.entries, path, false, err)
+					continue
+				}
+				return fmt.Errorf("cannot read symbolic link %s: %w", path, err)
+			}
+			linkTarget = filepath.ToSlash(target)
+		}
+
+		// Stat follows symbolic links
+		info, err := os.Stat(path)
+		if err != nil {
+			if linkTarget != "" {
+				w.warn("skipped broken symbolic link", relPath, linkTarget)
+				continue
+			}
+			if w.scanner.KeepGoing {
+				w.entries = w.scanner.recordFailure(w.entries, path, d.IsDir(), err)
+				continue
+			}
+			return fmt.Errorf("cannot get info for %s: %w", path, err)
+		}
+
+		if linkTarget != "" && w.scanner.NoExternalSymlinks {
+			inside, err := w.insideRoot(path)
+			if err != nil {
+				return err
+			}
+			if !inside {
+				w.warn("skipped symbolic link pointing outside the root", relPath, linkTarget)
+				continue
+			}
+		}
+
+		isDir := info.IsDir()
+
+		// Apply filter
+		if w.scanner.Filter != nil && !w.scanner.Filter.ShouldInclude(path, isDir) {
+			continue
+		}
+
+		if !isDir {
+			w.entries = append(w.entries, FileEntry{
+				Path:       path,
+				RelPath:    relPath,
+				Size:       info.Size(),
+				LinkTarget: linkTarget,
+			})
+			continue
+		}
+
+		dir := subdir{path: path, relPath: relPath, target: linkTarget, info: info}
+		if linkTarget != "" {
+			w.pending = append(w.pending, dir)
+			continue
+		}
+		if err := w.addDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDir adds a directory and walks it unless it was already walked.
+func (w *symlinkWalker) addDir(dir subdir) error {
+	key := newDirKey(dir.path, dir.info)
+	if w.visited[key] {
+		if dir.target != "" {
+			w.warn("skipped symbolic link to a directory already scanned", dir.relPath, dir.target)
+		} else {
+			// Reached through a link to one of its parents after a link to itself
+			w.scanner.warnings = append(w.scanner.warnings,
+				fmt.Sprintf("skipped directory already scanned: %s", filepath.ToSlash(dir.relPath)))
+		}
+		return nil
+	}
+	w.visited[key] = true
+
+	w.entries = append(w.entries, FileEntry{
+		Path:       dir.path,
+		RelPath:    dir.relPath,
+		IsDir:      true,
+		Size:       dir.info.Size(),
+		LinkTarget: dir.target,
+	})
+	return w.walk(dir.path)
+}
+
+// insideRoot returns true if path, with symbolic links resolved, is inside the root.
+func (w *symlinkWalker) insideRoot(path string) (bool, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, fmt.Errorf("cannot resolve symbolic link %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(w.resolvedRoot, resolved)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel), nil
+}
+
+// warn records a skipped symbolic link.
+func (w *symlinkWalker) warn(reason, relPath, target string) {
+	w.scanner.warnings = append(w.scanner.warnings,
+		fmt.Sprintf("%s: %s -> %s", reason, filepath.ToSlash(relPath), target))
+}
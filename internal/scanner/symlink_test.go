@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// symlink creates a symbolic link, skipping the test if links are not supported.
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+}
+
+// setupSymlinkTree creates a workspace with links to a shared directory
+// outside of it, a link back to its own root and a broken link:
+//
+//	shared/proto/a.proto
+//	ws/svc/main.go
+//	ws/svc/alias.go -> main.go
+//	ws/svc/proto -> ../../shared/proto
+//	ws/svc/loop -> ..
+//	ws/broken -> nowhere
+func setupSymlinkTree(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"shared/proto", "ws/svc"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"shared/proto/a.proto": "syntax = \"proto3\";\n",
+		"ws/svc/main.go":       "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	symlink(t, "main.go", filepath.Join(tmpDir, "ws", "svc", "alias.go"))
+	symlink(t, filepath.Join("..", "..", "shared", "proto"), filepath.Join(tmpDir, "ws", "svc", "proto"))
+	symlink(t, "..", filepath.Join(tmpDir, "ws", "svc", "loop"))
+	symlink(t, "nowhere", filepath.Join(tmpDir, "ws", "broken"))
+	return tmpDir
+}
+
+func relPaths(entries []FileEntry) []string {
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, filepath.ToSlash(entry.RelPath))
+	}
+	return paths
+}
+
+func TestScanner_FollowSymlinks(t *testing.T) {
+	tmpDir := setupSymlinkTree(t)
+
+	scanner, err := NewScanner(filepath.Join(tmpDir, "ws"), nil)
+	if err != nil {
+		t.Fatalf("NewScanner failed: %v", err)
+	}
+	scanner.FollowSymlinks = true
+
+	entries, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := []string{"svc", "svc/alias.go", "svc/main.go", "svc/proto", "svc/proto/a.proto"}
+	if !reflect.DeepEqual(relPaths(entries), expected) {
+		t.Errorf("Expected %v, got %v", expected, relPaths(entries))
+	}
+
+	for _, entry := range entries {
+		switch filepath.ToSlash(entry.RelPath) {
+		case "svc/alias.go":
+			if entry.LinkTarget != "main.go" || entry.Size != int64(len("package main\n")) {
+				t.Errorf("Expected link to main.go with target size, got %+v", entry)
+			}
+		case "svc/proto":
+			if entry.LinkTarget != "../../shared/proto" || !entry.IsDir {
+				t.Errorf("Expected directory link to shared/proto, got %+v", entry)
+			}
+		case "svc/proto/a.proto":
+			if entry.LinkTarget != "" {
+				t.Errorf("Expected file inside linked directory not to be a link, got %+v", entry)
+			}
+		}
+	}
+
+	expectedWarnings := []string{
+		"skipped broken symbolic link: broken -> nowhere",
+		"skipped symbolic link to a directory already scanned: svc/loop -> ..",
+	}
+	if !reflect.DeepEqual(scanner.Warnings(), expectedWarnings) {
+		t.Errorf("Expected warnings %v, got %v", expectedWarnings, scanner.Warnings())
+	}
+}
+
+func TestScanner_NoExternalSymlinks(t *testing.T) {
+	tmpDir := setupSymlinkTree(t)
+
+	scanner, err := NewScanner(filepath.Join(tmpDir, "ws"), nil)
+	if err != nil {
+		t.Fatalf("NewScanner failed: %v", err)
+	}
+	scanner.FollowSymlinks = true
+	scanner.NoExternalSymlinks = true
+
+	entries, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := []string{"svc", "svc/alias.go", "svc/main.go"}
+	if !reflect.DeepEqual(relPaths(entries), expected) {
+		t.Errorf("Expected %v, got %v", expected, relPaths(entries))
+	}
+
+	found := false
+	for _, warning := range scanner.Warnings() {
+		if warning == "skipped symbolic link pointing outside the root: svc/proto -> ../../shared/proto" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected warning for external link, got %v", scanner.Warnings())
+	}
+}
+
+func TestScanner_FollowSymlinksPrefersRealLocation(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "real"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "real", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	// "alias" sorts before "real" but the directory is listed at its real location
+	symlink(t, "real", filepath.Join(tmpDir, "alias"))
+
+	scanner, err := NewScanner(tmpDir, nil)
+	if err != nil {
+		t.Fatalf("NewScanner failed: %v", err)
+	}
+	scanner.FollowSymlinks = true
+
+	entries, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := []string{"real", "real/a.txt"}
+	if !reflect.DeepEqual(relPaths(entries), expected) {
+		t.Errorf("Expected %v, got %v", expected, relPaths(entries))
+	}
+	if len(scanner.Warnings()) != 1 {
+		t.Errorf("Expected a warning for the skipped link, got %v", scanner.Warnings())
+	}
+}
@@ -0,0 +1,136 @@
+package sniff
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// SampleSize is the number of leading bytes inspected to detect binary content.
+const SampleSize = 8000
+
+// invalidUTF8Threshold is the ratio of invalid UTF-8 bytes above which
+// content is considered binary.
+const invalidUTF8Threshold = 0.3
+
+// controlThreshold is the ratio of non-text control bytes above which
+// content is considered binary.
+const controlThreshold = 0.1
+
+// legacyEncodings are the multi-byte encodings whose text is mostly invalid
+// UTF-8. Single-byte encodings such as Windows-1252 are left out, since
+// almost any bytes decode cleanly with them.
+var legacyEncodings = []encoding.Encoding{
+	japanese.ShiftJIS,
+	japanese.EUCJP,
+	simplifiedchinese.GBK,
+	traditionalchinese.Big5,
+	korean.EUCKR,
+}
+
+// Result holds the outcome of binary detection.
+type Result struct {
+	Binary   bool   // Whether the content looks like binary data
+	MIMEType string // Detected MIME type (e.g., image/png), without parameters
+}
+
+// Detect inspects the leading bytes of content and reports whether it is binary.
+// Content is binary if it contains NUL bytes, has too many non-text control
+// bytes, or (when checkUTF8 is true) has too many invalid UTF-8 sequences
+// and doesn't decode cleanly as a legacy encoding such as Shift_JIS.
+// checkUTF8 should be false for files decoded from another encoding.
+func Detect(content []byte, checkUTF8 bool) Result {
+	sample := content
+	if len(sample) > SampleSize {
+		sample = sample[:SampleSize]
+	}
+
+	mimeType := http.DetectContentType(sample)
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	truncated := len(sample) < len(content)
+	binary := bytes.IndexByte(sample, 0) >= 0 ||
+		ratio(countControl(sample), len(sample)) > controlThreshold ||
+		(checkUTF8 && ratio(countInvalidUTF8(sample, truncated), len(sample)) > invalidUTF8Threshold &&
+			!decodesAsLegacy(sample, truncated))
+
+	if binary && strings.HasPrefix(mimeType, "text/") {
+		// Heuristics say binary even though no signature matched
+		mimeType = "application/octet-stream"
+	}
+
+	return Result{
+		Binary:   binary,
+		MIMEType: mimeType,
+	}
+}
+
+// ratio returns n/total, or 0 for empty content.
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// countControl counts control bytes that don't appear in ordinary text.
+// Tab, newlines, form feed, backspace and escape (used by ANSI colors) are allowed.
+func countControl(sample []byte) int {
+	count := 0
+	for _, b := range sample {
+		if b < 0x20 {
+			switch b {
+			case '\t', '\n', '\r', '\f', '\v', '\b', 0x1b:
+				continue
+			}
+			count++
+		}
+	}
+	return count
+}
+
+// countInvalidUTF8 counts bytes that are not part of a valid UTF-8 sequence.
+// If truncated is true, an incomplete sequence at the end is not counted.
+func countInvalidUTF8(sample []byte, truncated bool) int {
+	count := 0
+	for len(sample) > 0 {
+		r, size := utf8.DecodeRune(sample)
+		if r == utf8.RuneError && size <= 1 {
+			if truncated && !utf8.FullRune(sample) {
+				break
+			}
+			count++
+			size = 1
+		}
+		sample = sample[size:]
+	}
+	return count
+}
+
+// decodesAsLegacy reports whether the sample decodes without errors in one
+// of the legacy encodings. If truncated is true, an incomplete sequence at
+// the end is ignored.
+func decodesAsLegacy(sample []byte, truncated bool) bool {
+	// A multi-byte sequence never decodes to more than 3 bytes per input byte
+	dst := make([]byte, len(sample)*3)
+	for _, enc := range legacyEncodings {
+		nDst, _, err := enc.NewDecoder().Transform(dst, sample, !truncated)
+		if err != nil && err != transform.ErrShortSrc {
+			continue
+		}
+		if !bytes.ContainsRune(dst[:nDst], utf8.RuneError) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package sniff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestDetect(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0x00, 0x10, 0xff}, 10)...)
+	shiftJIS := []byte{0x82, 0xb1, 0x82, 0xf1, 0x82, 0xc9, 0x82, 0xbf, 0x82, 0xcd}
+	invalid := bytes.Repeat([]byte{0xff, 0xfe, 0x80}, 10)
+
+	tests := []struct {
+		name         string
+		content      []byte
+		checkUTF8    bool
+		expectBinary bool
+		expectMIME   string
+	}{
+		{"empty", []byte{}, true, false, "text/plain"},
+		{"ascii text", []byte("package main\n"), true, false, "text/plain"},
+		{"utf-8 text", []byte("こんにちは, 世界\n"), true, false, "text/plain"},
+		{"ansi colors", []byte("\x1b[31mred\x1b[0m\n"), true, false, "text/plain"},
+		{"png", png, true, true, "image/png"},
+		{"nul byte", []byte("abc\x00def"), true, true, "application/octet-stream"},
+		{"control bytes", []byte("\x01\x02\x03\x04abc"), true, true, "application/octet-stream"},
+		{"shift_jis", shiftJIS, true, false, "text/plain"},
+		{"invalid utf-8", invalid, true, true, "application/octet-stream"},
+		{"invalid utf-8 without check", invalid, false, false, "text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Detect(tt.content, tt.checkUTF8)
+			if result.Binary != tt.expectBinary {
+				t.Errorf("Binary = %v, want %v", result.Binary, tt.expectBinary)
+			}
+			if result.MIMEType != tt.expectMIME {
+				t.Errorf("MIMEType = %q, want %q", result.MIMEType, tt.expectMIME)
+			}
+		})
+	}
+}
+
+func TestDetect_TruncatedSample(t *testing.T) {
+	// A multi-byte character cut at the sample boundary must not count as invalid
+	content := bytes.Repeat([]byte("あ"), SampleSize)
+	if Detect(content, true).Binary {
+		t.Error("Expected UTF-8 text cut at the sample boundary to be text")
+	}
+}
+
+func TestDetect_JapaneseLegacyEncodings(t *testing.T) {
+	// Mostly invalid UTF-8, but clean text in a legacy encoding
+	text := strings.Repeat("日本語のテキスト、ｶﾀｶﾅ。\n", SampleSize/10)
+
+	tests := []struct {
+		name     string
+		encoding encoding.Encoding
+	}{
+		{"Shift_JIS", japanese.ShiftJIS},
+		{"EUC-JP", japanese.EUCJP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := tt.encoding.NewEncoder().Bytes([]byte(text))
+			if err != nil {
+				t.Fatalf("Failed to encode %s: %v", tt.name, err)
+			}
+			if ratio(countInvalidUTF8(content[:SampleSize], true), SampleSize) <= invalidUTF8Threshold {
+				t.Fatalf("Expected %s content to be mostly invalid UTF-8", tt.name)
+			}
+			if result := Detect(content, true); result.Binary || result.MIMEType != "text/plain" {
+				t.Errorf("Expected %s text not to be binary, got %+v", tt.name, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Use the embedded BPE vocabularies instead of downloading them
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// Tokenizer counts tokens in text.
+type Tokenizer interface {
+	Count(text string) int
+	Name() string
+}
+
+// New creates a Tokenizer by name.
+// Supported names:
+//   - cl100k: BPE vocabulary used by GPT-4 and GPT-3.5
+//   - o200k: BPE vocabulary used by GPT-4o
+//   - estimate: cheap estimation of one token per four characters
+func New(name string) (Tokenizer, error) {
+	switch strings.ToLower(name) {
+	case "estimate":
+		return NewEstimator(), nil
+	case "cl100k", "cl100k_base":
+		return newBPE("cl100k", tiktoken.MODEL_CL100K_BASE)
+	case "o200k", "o200k_base":
+		return newBPE("o200k", tiktoken.MODEL_O200K_BASE)
+	}
+	return nil, fmt.Errorf("unsupported tokenizer: %s (expected cl100k, o200k or estimate)", name)
+}
+
+// bpe counts tokens with a byte pair encoding vocabulary.
+type bpe struct {
+	name     string
+	encoding *tiktoken.Tiktoken
+}
+
+func newBPE(name, encodingName string) (*bpe, error) {
+	encoding, err := tiktoken.GetEncoding(encodingName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s vocabulary: %w", name, err)
+	}
+	return &bpe{name: name, encoding: encoding}, nil
+}
+
+// Count returns the number of tokens. Special tokens are treated as ordinary text.
+func (t *bpe) Count(text string) int {
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// Name returns the tokenizer name.
+func (t *bpe) Name() string {
+	return t.name
+}
+
+// estimator estimates tokens as one per four characters.
+type estimator struct{}
+
+// NewEstimator creates a Tokenizer that estimates tokens without a vocabulary.
+func NewEstimator() Tokenizer {
+	return &estimator{}
+}
+
+// Count returns the estimated number of tokens (characters / 4, rounded up).
+func (t *estimator) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Name returns the tokenizer name.
+func (t *estimator) Name() string {
+	return "estimate"
+}
@@ -0,0 +1,82 @@
+package tokenizer
+
+import (
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectedName string
+		wantErr      bool
+	}{
+		{"cl100k", "cl100k", false},
+		{"cl100k_base", "cl100k", false},
+		{"O200K", "o200k", false},
+		{"estimate", "estimate", false},
+		{"unknown", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := New(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if err == nil && tok.Name() != tt.expectedName {
+				t.Errorf("Name() = %q, want %q", tok.Name(), tt.expectedName)
+			}
+		})
+	}
+}
+
+func TestCount_BPE(t *testing.T) {
+	tests := []struct {
+		tokenizer string
+		text      string
+		expected  int
+	}{
+		{"cl100k", "", 0},
+		{"cl100k", "hello world", 2},
+		{"cl100k", "package main\n", 3},
+		{"o200k", "hello world", 2},
+		// Special tokens are counted as ordinary text instead of failing
+		{"cl100k", "<|endoftext|>", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tokenizer+"/"+tt.text, func(t *testing.T) {
+			tok, err := New(tt.tokenizer)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if got := tok.Count(tt.text); got != tt.expected {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCount_Estimate(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"日本語の文章", 2}, // Counted by characters, not bytes
+	}
+
+	tok, err := New("estimate")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		if got := tok.Count(tt.text); got != tt.expected {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.expected)
+		}
+	}
+}
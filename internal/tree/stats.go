@@ -0,0 +1,108 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stats holds statistics of a node shown next to its name by Render.
+// For directories, the values are aggregated over all descendant files.
+type Stats struct {
+	Files  int   // Number of files (1 for a file)
+	Size   int64 // Size in bytes
+	Lines  int   // Number of lines (0 for binary files)
+	Tokens int   // Number of tokens (0 for binary files)
+	Binary bool  // Whether the file is binary (files only)
+}
+
+// Annotate sets Stats on every node of the tree.
+// files maps file paths (Node.Path) to their statistics; directories get the
+// sum of their descendants. Files missing from the map get zero statistics.
+func Annotate(root *Node, files map[string]Stats) {
+	annotateNode(root, files)
+}
+
+// annotateNode sets Stats on the node and its descendants and returns the node's statistics.
+func annotateNode(node *Node, files map[string]Stats) Stats {
+	if !node.IsDir {
+		stats := files[node.Path]
+		stats.Files = 1
+		node.Stats = &stats
+		return stats
+	}
+
+	var total Stats
+	for _, child := range node.Children {
+		stats := annotateNode(child, files)
+		total.Files += stats.Files
+		total.Size += stats.Size
+		total.Lines += stats.Lines
+		total.Tokens += stats.Tokens
+	}
+	node.Stats = &total
+	return total
+}
+
+// formatStats formats the statistics of a node,
+// e.g. "14 files, 1,024 lines, 38.2 KiB, ~9.1k tok" for a directory.
+func formatStats(stats *Stats, isDir bool) string {
+	var parts []string
+	if isDir {
+		parts = append(parts, plural(stats.Files, "file"))
+	}
+	if stats.Binary {
+		parts = append(parts, "binary")
+	} else {
+		parts = append(parts, plural(stats.Lines, "line"))
+	}
+	parts = append(parts, FormatSize(stats.Size))
+	if !stats.Binary {
+		parts = append(parts, "~"+formatCount(stats.Tokens)+" tok")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plural formats a count with a noun, adding "s" unless the count is 1.
+func plural(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return formatThousands(count) + " " + noun + "s"
+}
+
+// formatThousands formats an integer with comma separators (e.g., 18,234).
+func formatThousands(n int) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatCount formats a count compactly (e.g., 850, 9.1k, 1.2M).
+func formatCount(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 1000000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	}
+}
+
+// FormatSize formats a byte count in human-readable binary units (e.g., 12.3 KiB).
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TiB", value)
+}
@@ -0,0 +1,100 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/onozaty/treecat/internal/scanner"
+)
+
+func TestAnnotate(t *testing.T) {
+	entries := []scanner.FileEntry{
+		{RelPath: "README.md", IsDir: false},
+		{RelPath: "internal", IsDir: true},
+		{RelPath: "internal/a.go", IsDir: false},
+		{RelPath: "internal/b.go", IsDir: false},
+		{RelPath: "internal/logo.png", IsDir: false},
+	}
+	root := Build(entries, "project")
+
+	Annotate(root, map[string]Stats{
+		"README.md":         {Size: 100, Lines: 5, Tokens: 30},
+		"internal/a.go":     {Size: 2048, Lines: 80, Tokens: 600},
+		"internal/b.go":     {Size: 1024, Lines: 1, Tokens: 1000},
+		"internal/logo.png": {Size: 5000, Binary: true},
+	})
+
+	if root.Stats == nil {
+		t.Fatal("Expected root stats to be set")
+	}
+	expectedRoot := Stats{Files: 4, Size: 8172, Lines: 86, Tokens: 1630}
+	if *root.Stats != expectedRoot {
+		t.Errorf("Expected root stats %+v, got %+v", expectedRoot, *root.Stats)
+	}
+
+	result := Render(root)
+	expected := "project (4 files, 86 lines, 8.0 KiB, ~1.6k tok)\n" +
+		"├── internal/ (3 files, 81 lines, 7.9 KiB, ~1.6k tok)\n" +
+		"│   ├── a.go (80 lines, 2.0 KiB, ~600 tok)\n" +
+		"│   ├── b.go (1 line, 1.0 KiB, ~1.0k tok)\n" +
+		"│   └── logo.png (binary, 4.9 KiB)\n" +
+		"└── README.md (5 lines, 100 B, ~30 tok)\n"
+	if result != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, result)
+	}
+}
+
+func TestFormatThousands(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{18234, "18,234"},
+		{1234567, "1,234,567"},
+	}
+
+	for _, tt := range tests {
+		if got := formatThousands(tt.n); got != tt.expected {
+			t.Errorf("formatThousands(%d) = %q, want %q", tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{0, "0"},
+		{850, "850"},
+		{9100, "9.1k"},
+		{1200000, "1.2M"},
+	}
+
+	for _, tt := range tests {
+		if got := formatCount(tt.n); got != tt.expected {
+			t.Errorf("formatCount(%d) = %q, want %q", tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{12595, "12.3 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.size); got != tt.expected {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.size, got, tt.expected)
+		}
+	}
+}
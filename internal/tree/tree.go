@@ -10,10 +10,15 @@ import (
 
 // Node represents a node in the directory tree.
 type Node struct {
-	Name     string  // File or directory name
-	Path     string  // Relative path from root
-	IsDir    bool    // Whether this is a directory
-	Children []*Node // Child nodes (for directories)
+	Name      string  // File or directory name
+	Path      string  // Relative path from root
+	IsDir     bool    // Whether this is a directory
+	Children  []*Node // Child nodes (for directories)
+	Stats     *Stats  // Statistics shown by Render (nil to omit, see Annotate)
+	Truncated bool    // Whether the file content is truncated in the output
+
+	LinkTarget string // Target of a followed symbolic link (empty otherwise)
+	Error      string // Why the file or directory could not be read (empty otherwise)
 }
 
 // Build builds a tree structure from a flat list of file entries.
@@ -48,6 +53,12 @@ func Build(entries []scanner.FileEntry, rootName string) *Node {
 				}
 				current.Children = append(current.Children, child)
 			}
+			if isLast {
+				child.LinkTarget = entry.LinkTarget
+				if entry.Err != nil {
+					child.Error = scanner.ErrorReason(entry.Err)
+				}
+			}
 
 			current = child
 		}
@@ -62,6 +73,18 @@ func Build(entries []scanner.FileEntry, rootName string) *Node {
 	return root
 }
 
+// Find returns the node at the given slash-separated path, or nil if not found.
+func Find(root *Node, path string) *Node {
+	current := root
+	for _, part := range strings.Split(path, "/") {
+		current = findChild(current, part)
+		if current == nil {
+			return nil
+		}
+	}
+	return current
+}
+
 // findChild finds a child node by name.
 func findChild(node *Node, name string) *Node {
 	for _, child := range node.Children {
@@ -95,9 +118,10 @@ func sortNode(node *Node) {
 // A directory has descendant files if:
 // - It directly contains at least one file (non-directory child)
 // - At least one of its child directories has descendant files
+// - It could not be read, so its contents are unknown
 func hasDescendantFiles(node *Node) bool {
-	if !node.IsDir {
-		return true // Files always count
+	if !node.IsDir || node.Error != "" {
+		return true // Files and unreadable directories always count
 	}
 
 	for _, child := range node.Children {
@@ -144,6 +168,7 @@ func Render(root *Node) string {
 	// Write root directory name as first line
 	if root.Name != "" {
 		builder.WriteString(root.Name)
+		writeStats(root, &builder)
 		builder.WriteString("\n")
 	}
 
@@ -171,6 +196,16 @@ func renderNode(node *Node, prefix string, isLast bool, builder *strings.Builder
 	if node.IsDir {
 		builder.WriteString("/")
 	}
+	if node.LinkTarget != "" {
+		builder.WriteString(" -> " + node.LinkTarget)
+	}
+	writeStats(node, builder)
+	if node.Truncated {
+		builder.WriteString(" [truncated]")
+	}
+	if node.Error != "" {
+		builder.WriteString(" [error: " + node.Error + "]")
+	}
 	builder.WriteString("\n")
 
 	// Render children
@@ -186,3 +221,13 @@ func renderNode(node *Node, prefix string, isLast bool, builder *strings.Builder
 		renderNode(child, childPrefix, isLastChild, builder)
 	}
 }
+
+// writeStats writes the statistics of the node in parentheses, if set.
+func writeStats(node *Node, builder *strings.Builder) {
+	if node.Stats == nil {
+		return
+	}
+	builder.WriteString(" (")
+	builder.WriteString(formatStats(node.Stats, node.IsDir))
+	builder.WriteString(")")
+}
@@ -1,6 +1,8 @@
 package tree
 
 import (
+	"errors"
+	"io/fs"
 	"strings"
 	"testing"
 
@@ -393,4 +395,42 @@ func TestPruneEmptyDirectories_OnlyFiles(t *testing.T) {
 	if root.Children[1].Name != "file2.txt" || root.Children[1].IsDir {
 		t.Errorf("Expected file2.txt (file), got %s (isDir=%v)", root.Children[1].Name, root.Children[1].IsDir)
 	}
-}
\ No newline at end of file
+}
+
+func TestBuildAndRender_SymbolicLinks(t *testing.T) {
+	entries := []scanner.FileEntry{
+		{RelPath: "main.go", IsDir: false},
+		{RelPath: "proto", IsDir: true, LinkTarget: "../shared/proto"},
+		{RelPath: "proto/a.proto", IsDir: false},
+		{RelPath: "alias.go", IsDir: false, LinkTarget: "main.go"},
+	}
+	result := Render(Build(entries, "svc"))
+
+	expected := `svc
+├── proto/ -> ../shared/proto
+│   └── a.proto
+├── alias.go -> main.go
+└── main.go
+`
+	if result != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, result)
+	}
+}
+
+func TestBuildAndRender_Errors(t *testing.T) {
+	entries := []scanner.FileEntry{
+		{RelPath: "locked", IsDir: true, Err: &fs.PathError{Op: "open", Path: "locked", Err: fs.ErrPermission}},
+		{RelPath: "main.go", IsDir: false},
+		{RelPath: "secret.txt", IsDir: false, Err: errors.New("permission denied")},
+	}
+	// Unreadable directories are kept even though they have no files
+	result := Render(Build(entries, ""))
+
+	expected := `├── locked/ [error: permission denied]
+├── main.go
+└── secret.txt [error: permission denied]
+`
+	if result != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, result)
+	}
+}
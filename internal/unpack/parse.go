@@ -0,0 +1,325 @@
+package unpack
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/onozaty/treecat/internal/output"
+)
+
+// Format specifies the format of a bundle.
+type Format int
+
+const (
+	// FormatAuto detects the format from the content.
+	FormatAuto Format = iota
+	// FormatPlain is the plain output ("=== path ===" markers or boundaries).
+	FormatPlain
+	// FormatMarkdown is the markdown output ("## path" headings with fenced code blocks).
+	FormatMarkdown
+	// FormatXML is the XML document output.
+	FormatXML
+)
+
+// ParseFormat parses a bundle format name (auto, plain, markdown, xml).
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return FormatAuto, nil
+	case "plain":
+		return FormatPlain, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "xml":
+		return FormatXML, nil
+	}
+	return FormatAuto, fmt.Errorf("invalid bundle format: %s (expected auto, plain, markdown or xml)", name)
+}
+
+// File is a file extracted from a bundle.
+type File struct {
+	Path        string // Path as written in the bundle (slash-separated)
+	Content     []byte // File content
+	Placeholder bool   // Whether the content is a placeholder (binary, unreadable or skipped file) rather than the file itself
+	Truncated   bool   // Whether lines of the content were replaced with a truncation marker
+}
+
+var (
+	// plainMarkerPattern matches "=== path ===" file markers.
+	plainMarkerPattern = regexp.MustCompile(`^=== (.+) ===$`)
+	// partHeaderPattern matches the header of a split output part.
+	partHeaderPattern = regexp.MustCompile(`^(--- part \d+ of \d+ ---|<!-- part \d+ of \d+ -->)$`)
+	// lineRangePattern matches the line range appended to the path of a file split across parts.
+	lineRangePattern = regexp.MustCompile(` \(lines (\d+)-(\d+) of (\d+)\)$`)
+	// placeholderPattern matches the placeholder written instead of binary, unreadable or skipped content.
+	placeholderPattern = regexp.MustCompile(`^\[(binary file, |unreadable file: |skipped file: )[^\]\n]+\]\n$`)
+	// truncationPattern matches the line replacing the lines removed by the
+	// per-file limits or the token budget, e.g. "… [truncated 18,234 lines] …".
+	truncationPattern = regexp.MustCompile(`(?m)^… \[truncated [\d,]+ (lines?|bytes?)(: [^\]\n]+)?\] …$`)
+)
+
+// Parse extracts the files from one or more bundles. The bundles are parsed
+// in order, so the parts of a split output can be given together. Files split
+// across parts (with line ranges appended to their paths) are joined back
+// together when their pieces are consecutive.
+func Parse(format Format, bundles ...[]byte) ([]File, error) {
+	var pieces []piece
+	for _, data := range bundles {
+		// Normalize newlines so bundles edited on Windows can be parsed as well
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+		bundleFormat := format
+		if bundleFormat == FormatAuto {
+			bundleFormat = detectFormat(data)
+		}
+
+		var ps []piece
+		var err error
+		switch bundleFormat {
+		case FormatXML:
+			ps, err = parseXML(data)
+		case FormatMarkdown:
+			ps, err = parseMarkdown(data)
+		default:
+			ps, err = parsePlain(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, ps...)
+	}
+
+	return joinPieces(pieces)
+}
+
+// piece is a file, or a range of its lines, as found in the bundle.
+type piece struct {
+	header  string // Path with an optional line range
+	content []byte
+}
+
+// detectFormat detects the bundle format from the first meaningful line.
+func detectFormat(data []byte) Format {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if line == "" || partHeaderPattern.MatchString(line) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "<documents>"), strings.HasPrefix(line, "<?xml"):
+			return FormatXML
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "## "):
+			return FormatMarkdown
+		}
+		return FormatPlain
+	}
+	return FormatPlain
+}
+
+// parsePlain parses the plain output. The text before the first file marker
+// (the tree, or anything else) is ignored.
+func parsePlain(data []byte) ([]piece, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+
+	// Use the boundary if one is declared
+	boundary := ""
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\n")
+		if strings.HasPrefix(line, output.BoundaryDeclarationPrefix) && strings.HasSuffix(line, " ===") {
+			boundary = strings.TrimSuffix(strings.TrimPrefix(line, output.BoundaryDeclarationPrefix), " ===")
+			break
+		}
+	}
+
+	// header returns the file header of a marker line, or false for other lines
+	header := func(line string) (string, bool) {
+		if boundary != "" {
+			return strings.CutPrefix(line, "--"+boundary+" ")
+		}
+		if match := plainMarkerPattern.FindStringSubmatch(line); match != nil {
+			return match[1], true
+		}
+		return "", false
+	}
+
+	var pieces []piece
+	var current *piece
+	var content strings.Builder
+	flush := func() {
+		if current != nil {
+			// The renderer adds a newline after the content
+			current.content = []byte(strings.TrimSuffix(content.String(), "\n"))
+			pieces = append(pieces, *current)
+		}
+		current = nil
+		content.Reset()
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSuffix(line, "\n")
+		if boundary != "" && trimmed == "--"+boundary+"--" {
+			flush()
+			break
+		}
+		if h, ok := header(trimmed); ok {
+			flush()
+			current = &piece{header: h}
+			continue
+		}
+		if current != nil {
+			content.WriteString(line)
+		}
+	}
+	flush()
+
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("no files found in plain bundle")
+	}
+	return pieces, nil
+}
+
+// parseMarkdown parses the markdown output: a "## path" heading followed by a
+// fenced code block (or a placeholder line for binary files).
+func parseMarkdown(data []byte) ([]piece, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+
+	var pieces []piece
+	for i := 0; i < len(lines); i++ {
+		path, ok := strings.CutPrefix(strings.TrimSuffix(lines[i], "\n"), "## ")
+		if !ok {
+			continue
+		}
+
+		// Skip blank lines after the heading
+		j := i + 1
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j >= len(lines) {
+			break
+		}
+
+		opening := strings.TrimSuffix(lines[j], "\n")
+		if !strings.HasPrefix(opening, "```") {
+			// Binary placeholder written as a plain line
+			pieces = append(pieces, piece{header: path, content: []byte(lines[j])})
+			i = j
+			continue
+		}
+
+		fence := opening[:len(opening)-len(strings.TrimLeft(opening, "`"))]
+		var content strings.Builder
+		closed := false
+		for j++; j < len(lines); j++ {
+			if strings.TrimRight(lines[j], "\n") == fence {
+				closed = true
+				break
+			}
+			content.WriteString(lines[j])
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated code block for %s", path)
+		}
+
+		pieces = append(pieces, piece{header: path, content: []byte(content.String())})
+		i = j
+	}
+
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("no files found in markdown bundle")
+	}
+	return pieces, nil
+}
+
+// parseXML parses the XML document output.
+func parseXML(data []byte) ([]piece, error) {
+	var documents struct {
+		Documents []struct {
+			Source  string `xml:"source"`
+			Content string `xml:"document_content"`
+		} `xml:"document"`
+	}
+	if err := xml.Unmarshal(data, &documents); err != nil {
+		return nil, fmt.Errorf("failed to parse XML bundle: %w", err)
+	}
+
+	var pieces []piece
+	for _, document := range documents.Documents {
+		// The renderer puts the content on the line after the opening tag
+		content := strings.TrimPrefix(document.Content, "\n")
+		pieces = append(pieces, piece{header: document.Source, content: []byte(content)})
+	}
+
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("no files found in XML bundle")
+	}
+	return pieces, nil
+}
+
+// joinPieces joins consecutive pieces of files split across parts and
+// rejects files appearing more than once or missing some of their lines.
+func joinPieces(pieces []piece) ([]File, error) {
+	var files []File
+	seen := make(map[string]bool)
+
+	// Line range state of the last file when it is split across parts
+	nextLine, totalLines := 0, 0
+	checkComplete := func() error {
+		if nextLine > 0 && nextLine <= totalLines {
+			return fmt.Errorf("missing lines %d-%d of %s", nextLine, totalLines, files[len(files)-1].Path)
+		}
+		return nil
+	}
+
+	for _, p := range pieces {
+		path, first, last, total := p.header, 0, 0, 0
+		if match := lineRangePattern.FindStringSubmatch(p.header); match != nil {
+			path = strings.TrimSuffix(p.header, match[0])
+			first, _ = strconv.Atoi(match[1])
+			last, _ = strconv.Atoi(match[2])
+			total, _ = strconv.Atoi(match[3])
+		}
+
+		// Continuation of the previous file
+		if first > 1 && len(files) > 0 && files[len(files)-1].Path == path {
+			if first != nextLine {
+				return nil, fmt.Errorf("missing lines %d-%d of %s", nextLine, first-1, path)
+			}
+			files[len(files)-1].Content = append(files[len(files)-1].Content, p.content...)
+			nextLine = last + 1
+			continue
+		}
+
+		if err := checkComplete(); err != nil {
+			return nil, err
+		}
+		if first > 1 {
+			return nil, fmt.Errorf("missing lines 1-%d of %s", first-1, path)
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("duplicate file in bundle: %s", path)
+		}
+		seen[path] = true
+
+		files = append(files, File{
+			Path:        path,
+			Content:     p.content,
+			Placeholder: placeholderPattern.Match(p.content),
+		})
+		nextLine, totalLines = last+1, total
+	}
+
+	if err := checkComplete(); err != nil {
+		return nil, err
+	}
+
+	// Checked once the pieces of split files are joined
+	for i := range files {
+		files[i].Truncated = !files[i].Placeholder && truncationPattern.Match(files[i].Content)
+	}
+	return files, nil
+}
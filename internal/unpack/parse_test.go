@@ -0,0 +1,311 @@
+package unpack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onozaty/treecat/internal/encoding"
+	"github.com/onozaty/treecat/internal/output"
+	"github.com/onozaty/treecat/internal/scanner"
+	"github.com/onozaty/treecat/internal/tree"
+)
+
+// formatBundle writes files and formats them with the output package.
+func formatBundle(t *testing.T, files map[string]string, options output.Options) []byte {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	var entries []scanner.FileEntry
+	for _, name := range []string{"README.md", "src/main.go", "src/notes.txt"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		entries = append(entries, scanner.FileEntry{Path: path, RelPath: filepath.FromSlash(name)})
+	}
+
+	var buf bytes.Buffer
+	formatter := output.NewFormatterWithOptions(&buf, options)
+	if err := formatter.Format(tree.Build(entries, "project"), entries); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// assertFiles checks the parsed files against the expected contents in order.
+func assertFiles(t *testing.T, files []File, expected []File) {
+	t.Helper()
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %d files, got %d: %+v", len(expected), len(files), files)
+	}
+	for i := range expected {
+		if files[i].Path != expected[i].Path {
+			t.Errorf("File %d: expected path %q, got %q", i, expected[i].Path, files[i].Path)
+		}
+		if string(files[i].Content) != string(expected[i].Content) {
+			t.Errorf("File %s: expected content %q, got %q", expected[i].Path, expected[i].Content, files[i].Content)
+		}
+		if files[i].Placeholder != expected[i].Placeholder {
+			t.Errorf("File %s: expected placeholder %v, got %v", expected[i].Path, expected[i].Placeholder, files[i].Placeholder)
+		}
+	}
+}
+
+var roundTripFiles = map[string]string{
+	"README.md":     "# Project\n\n```go\nfmt.Println(\"<&>\")\n```\n",
+	"src/main.go":   "package main\n\nfunc main() {}\n",
+	"src/notes.txt": "no trailing newline",
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format output.Format
+		// Markdown code blocks always end with a newline
+		notes string
+	}{
+		{"plain", output.FormatPlain, "no trailing newline"},
+		{"markdown", output.FormatMarkdown, "no trailing newline\n"},
+		{"xml", output.FormatXML, "no trailing newline\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := formatBundle(t, roundTripFiles, output.Options{Format: tt.format})
+
+			files, err := Parse(FormatAuto, bundle)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			assertFiles(t, files, []File{
+				{Path: "README.md", Content: []byte(roundTripFiles["README.md"])},
+				{Path: "src/main.go", Content: []byte(roundTripFiles["src/main.go"])},
+				{Path: "src/notes.txt", Content: []byte(tt.notes)},
+			})
+		})
+	}
+}
+
+func TestParse_PlainBoundary(t *testing.T) {
+	files := map[string]string{
+		"README.md":   "=== fake.txt ===\nnot a file\n",
+		"src/main.go": "package main\n",
+	}
+	bundle := formatBundle(t, files, output.Options{})
+	if !bytes.Contains(bundle, []byte(output.BoundaryDeclarationPrefix)) {
+		t.Fatalf("Expected a boundary in the bundle, got:\n%s", bundle)
+	}
+
+	parsed, err := Parse(FormatPlain, bundle)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	assertFiles(t, parsed, []File{
+		{Path: "README.md", Content: []byte(files["README.md"])},
+		{Path: "src/main.go", Content: []byte(files["src/main.go"])},
+	})
+}
+
+func TestParse_SplitParts(t *testing.T) {
+	tmpDir := t.TempDir()
+	var content bytes.Buffer
+	for i := 0; i < 50; i++ {
+		content.WriteString("some log line\n")
+	}
+	path := filepath.Join(tmpDir, "app.log")
+	if err := os.WriteFile(path, content.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create app.log: %v", err)
+	}
+	entries := []scanner.FileEntry{{Path: path, RelPath: "app.log"}}
+
+	formatter := output.NewFormatterWithOptions(nil, output.Options{SplitSize: 300})
+	parts, err := formatter.FormatSplit(tree.Build(entries, ""), entries)
+	if err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+	if len(parts) < 3 {
+		t.Fatalf("Expected the file to be split into several parts, got %d", len(parts))
+	}
+
+	files, err := Parse(FormatAuto, parts...)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	assertFiles(t, files, []File{{Path: "app.log", Content: content.Bytes()}})
+
+	// A missing part is reported
+	if _, err := Parse(FormatAuto, parts[0], parts[2]); err == nil {
+		t.Error("Expected error for a missing part")
+	}
+}
+
+func TestParse_Placeholders(t *testing.T) {
+	bundle := []byte("=== logo.png ===\n[binary file, 2.0 KiB, image/png]\n\n" +
+		"=== secret.txt ===\n[unreadable file: permission denied]\n\n" +
+		"=== a.txt ===\na\n\n")
+
+	files, err := Parse(FormatAuto, bundle)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	assertFiles(t, files, []File{
+		{Path: "logo.png", Content: []byte("[binary file, 2.0 KiB, image/png]\n"), Placeholder: true},
+		{Path: "secret.txt", Content: []byte("[unreadable file: permission denied]\n"), Placeholder: true},
+		{Path: "a.txt", Content: []byte("a\n")},
+	})
+}
+
+func TestParse_SkippedFile(t *testing.T) {
+	files := map[string]string{
+		"README.md":     "# Project\n",
+		"src/notes.txt": "abc\xffd\n",
+	}
+
+	tests := []struct {
+		name   string
+		format output.Format
+	}{
+		{"plain", output.FormatPlain},
+		{"markdown", output.FormatMarkdown},
+		{"xml", output.FormatXML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := formatBundle(t, files, output.Options{Format: tt.format, InvalidBytes: encoding.InvalidSkipFile})
+
+			parsed, err := Parse(FormatAuto, bundle)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			assertFiles(t, parsed, []File{
+				{Path: "README.md", Content: []byte(files["README.md"])},
+				{Path: "src/notes.txt", Content: []byte("[skipped file: invalid utf-8 byte sequence at offset 3]\n"), Placeholder: true},
+			})
+		})
+	}
+}
+
+func TestParse_Truncated(t *testing.T) {
+	files := map[string]string{
+		"README.md":   "# Project\n",
+		"src/main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1)\n}\n",
+	}
+	bundle := formatBundle(t, files, output.Options{MaxFileLines: 4})
+
+	parsed, err := Parse(FormatAuto, bundle)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(parsed))
+	}
+	if parsed[0].Truncated {
+		t.Error("Expected README.md not to be truncated")
+	}
+	if !parsed[1].Truncated {
+		t.Errorf("Expected src/main.go to be truncated, got %q", parsed[1].Content)
+	}
+
+	// Markers of the token budget and of cut lines as well
+	for _, marker := range []string{"… [truncated 3 lines: token budget reached] …", "… [truncated 4,000 bytes] …"} {
+		parsed, err := Parse(FormatAuto, []byte("=== a.txt ===\na\n"+marker+"\n\n"))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if !parsed[0].Truncated {
+			t.Errorf("Expected %q to be detected", marker)
+		}
+	}
+}
+
+func TestParse_AnswerWithoutTree(t *testing.T) {
+	// Answers from LLMs often have text around the files
+	bundle := []byte("Here are the updated files:\r\n\r\n=== a.txt ===\r\nfixed\r\n\r\n")
+
+	files, err := Parse(FormatAuto, bundle)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	assertFiles(t, files, []File{{Path: "a.txt", Content: []byte("fixed\n")}})
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		bundle string
+	}{
+		{"no files", FormatAuto, "just some text\n"},
+		{"duplicate", FormatPlain, "=== a.txt ===\n1\n\n=== a.txt ===\n2\n\n"},
+		{"unterminated code block", FormatMarkdown, "## a.txt\n\n```\ncontent\n"},
+		{"invalid xml", FormatXML, "<documents><document>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.format, []byte(tt.bundle)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		bundle   string
+		expected Format
+	}{
+		{"project\n└── a.txt\n\n=== a.txt ===\n", FormatPlain},
+		{"```\nproject\n```\n\n## a.txt\n", FormatMarkdown},
+		{"<!-- part 1 of 2 -->\n\n```\n", FormatMarkdown},
+		{"<documents>\n<directory_tree>\n", FormatXML},
+		{"<!-- part 2 of 2 -->\n<documents>\n", FormatXML},
+		{"--- part 1 of 2 ---\n\nproject\n", FormatPlain},
+	}
+
+	for _, tt := range tests {
+		if got := detectFormat([]byte(tt.bundle)); got != tt.expected {
+			t.Errorf("detectFormat(%q) = %v, want %v", tt.bundle, got, tt.expected)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{"", FormatAuto, false},
+		{"auto", FormatAuto, false},
+		{"plain", FormatPlain, false},
+		{"md", FormatMarkdown, false},
+		{"XML", FormatXML, false},
+		{"json", FormatAuto, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseFormat(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package unpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy specifies what to do with files that already exist.
+type Policy int
+
+const (
+	// PolicyNever refuses to unpack if any file already exists.
+	PolicyNever Policy = iota
+	// PolicySkip keeps existing files and writes only new ones.
+	PolicySkip
+	// PolicyAlways overwrites existing files.
+	PolicyAlways
+)
+
+// ParsePolicy parses an overwrite policy name (never, skip, always).
+func ParsePolicy(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "never":
+		return PolicyNever, nil
+	case "skip":
+		return PolicySkip, nil
+	case "always":
+		return PolicyAlways, nil
+	}
+	return PolicyNever, fmt.Errorf("invalid overwrite policy: %s (expected never, skip or always)", name)
+}
+
+// Operation is what is done with a file.
+type Operation int
+
+const (
+	// OpCreate creates a new file.
+	OpCreate Operation = iota
+	// OpOverwrite replaces an existing file.
+	OpOverwrite
+	// OpSkip leaves the file untouched.
+	OpSkip
+)
+
+// String returns the name of the operation.
+func (op Operation) String() string {
+	switch op {
+	case OpCreate:
+		return "create"
+	case OpOverwrite:
+		return "overwrite"
+	default:
+		return "skip"
+	}
+}
+
+// Action is the planned action for a file.
+type Action struct {
+	File   File
+	Target string    // Destination path
+	Op     Operation // What to do with the file
+	Reason string    // Why the file is skipped (empty otherwise)
+}
+
+// Plan checks the destination of every file and decides what to do with it,
+// without writing anything. It fails if any path would escape destDir
+// (absolute paths, ".." components or symbolic links), or if a file already
+// exists and policy is PolicyNever. It also fails if a file to write would be
+// a parent directory of another one. Placeholders and truncated files are
+// skipped, since writing them would replace the files with partial content.
+func Plan(files []File, destDir string, policy Policy) ([]Action, error) {
+	var actions []Action
+	written := map[string]string{} // Targets of files to write to their paths
+	for _, file := range files {
+		target, exists, err := ResolveTarget(destDir, file.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		action := Action{File: file, Target: target, Op: OpCreate}
+		switch {
+		case file.Placeholder:
+			action.Op = OpSkip
+			action.Reason = "placeholder"
+		case file.Truncated:
+			action.Op = OpSkip
+			action.Reason = "truncated"
+		case exists && policy == PolicyNever:
+			return nil, fmt.Errorf("file already exists: %s (use --overwrite to replace or skip existing files)", file.Path)
+		case exists && policy == PolicySkip:
+			action.Op = OpSkip
+			action.Reason = "already exists"
+		case exists:
+			action.Op = OpOverwrite
+		}
+		if action.Op != OpSkip {
+			written[action.Target] = file.Path
+		}
+		actions = append(actions, action)
+	}
+
+	// Files on disk are checked by ResolveTarget, files of the bundle here
+	for _, action := range actions {
+		if action.Op == OpSkip {
+			continue
+		}
+		for dir := filepath.Dir(action.Target); dir != filepath.Dir(dir) && dir != filepath.Clean(destDir); dir = filepath.Dir(dir) {
+			if path, ok := written[dir]; ok {
+				return nil, fmt.Errorf("path is both a file and a directory: %s (parent of %s)", path, action.File.Path)
+			}
+		}
+	}
+	return actions, nil
+}
+
+// Apply writes the files of the actions, creating parent directories as needed.
+func Apply(actions []Action) error {
+	for _, action := range actions {
+		if action.Op == OpSkip {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(action.Target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", action.File.Path, err)
+		}
+		if err := os.WriteFile(action.Target, action.File.Content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", action.File.Path, err)
+		}
+	}
+	return nil
+}
+
+// ResolveTarget returns the destination of a slash- or backslash-separated
+// path inside destDir and whether a file already exists there. Paths that
+// would escape destDir (absolute paths, ".." components or symbolic links)
+// and paths of directories are rejected.
+func ResolveTarget(destDir, path string) (string, bool, error) {
+	if path == "" || strings.ContainsRune(path, 0) {
+		return "", false, fmt.Errorf("invalid path: %q", path)
+	}
+	// Check Windows drive letters on every platform since bundles may come from anywhere
+	hasDrive := len(path) >= 2 && path[1] == ':' &&
+		(('a' <= path[0] && path[0] <= 'z') || ('A' <= path[0] && path[0] <= 'Z'))
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, `\`) || hasDrive ||
+		filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return "", false, fmt.Errorf("refusing absolute path: %s", path)
+	}
+
+	// Accept both separators since bundles may come from any platform
+	var parts []string
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		switch part {
+		case ".":
+			continue
+		case "..":
+			return "", false, fmt.Errorf("refusing path escaping the destination: %s", path)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", false, fmt.Errorf("invalid path: %q", path)
+	}
+
+	// Refuse to write through symbolic links, which could point anywhere
+	current := destDir
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return filepath.Join(append([]string{current}, parts[i+1:]...)...), false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to check %s: %w", path, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", false, fmt.Errorf("refusing path through symbolic link: %s", path)
+		}
+		isLast := i == len(parts)-1
+		if !isLast && !info.IsDir() {
+			return "", false, fmt.Errorf("not a directory: %s", filepath.Join(parts[:i+1]...))
+		}
+		if isLast && info.IsDir() {
+			return "", false, fmt.Errorf("refusing to overwrite directory: %s", path)
+		}
+	}
+
+	return current, true, nil
+}
@@ -0,0 +1,180 @@
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	destDir := t.TempDir()
+
+	files := []File{
+		{Path: "src/main.go", Content: []byte("package main\n")},
+		{Path: "./README.md", Content: []byte("# Project\n")},
+		{Path: "logo.png", Content: []byte("[binary file, 2.0 KiB, image/png]\n"), Placeholder: true},
+		{Path: "big.txt", Content: []byte("a\n… [truncated 8 lines] …\nz\n"), Truncated: true},
+	}
+
+	actions, err := Plan(files, destDir, PolicyNever)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	expectedOps := []Operation{OpCreate, OpCreate, OpSkip, OpSkip}
+	for i, action := range actions {
+		if action.Op != expectedOps[i] {
+			t.Errorf("Action %d: expected %v, got %v", i, expectedOps[i], action.Op)
+		}
+	}
+	if actions[0].Target != filepath.Join(destDir, "src", "main.go") {
+		t.Errorf("Unexpected target: %s", actions[0].Target)
+	}
+
+	if err := Apply(actions); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "src", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to read main.go: %v", err)
+	}
+	if string(content) != "package main\n" {
+		t.Errorf("Unexpected content: %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "logo.png")); !os.IsNotExist(err) {
+		t.Error("Expected binary placeholder not to be written")
+	}
+	if actions[3].Reason != "truncated" {
+		t.Errorf("Expected truncated file to be skipped, got %q", actions[3].Reason)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "big.txt")); !os.IsNotExist(err) {
+		t.Error("Expected truncated file not to be written")
+	}
+}
+
+func TestPlan_Policies(t *testing.T) {
+	destDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(destDir, "a.txt"), []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to create a.txt: %v", err)
+	}
+
+	files := []File{
+		{Path: "a.txt", Content: []byte("new\n")},
+		{Path: "b.txt", Content: []byte("b\n")},
+	}
+
+	// never: refuse before writing anything
+	if _, err := Plan(files, destDir, PolicyNever); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected 'already exists' error, got: %v", err)
+	}
+
+	// skip: keep the existing file
+	actions, err := Plan(files, destDir, PolicySkip)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if actions[0].Op != OpSkip || actions[1].Op != OpCreate {
+		t.Errorf("Unexpected operations: %v, %v", actions[0].Op, actions[1].Op)
+	}
+
+	// always: overwrite the existing file
+	actions, err = Plan(files, destDir, PolicyAlways)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if actions[0].Op != OpOverwrite {
+		t.Errorf("Expected overwrite, got %v", actions[0].Op)
+	}
+	if err := Apply(actions); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(destDir, "a.txt"))
+	if string(content) != "new\n" {
+		t.Errorf("Expected a.txt to be overwritten, got %q", content)
+	}
+}
+
+func TestPlan_RejectsEscapingPaths(t *testing.T) {
+	destDir := t.TempDir()
+	outsideDir := t.TempDir()
+
+	if err := os.Symlink(outsideDir, filepath.Join(destDir, "link")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "file"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	paths := []string{
+		"/etc/passwd",
+		`\windows\system.ini`,
+		"C:/windows/system.ini",
+		"../outside.txt",
+		"src/../../outside.txt",
+		`src\..\..\outside.txt`,
+		"link/evil.txt",
+		"file/child.txt",
+		"",
+		".",
+	}
+
+	for _, path := range paths {
+		_, err := Plan([]File{{Path: path, Content: []byte("x")}}, destDir, PolicyAlways)
+		if err == nil {
+			t.Errorf("Expected error for path %q", path)
+		}
+	}
+
+	entries, _ := os.ReadDir(outsideDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing written outside the destination, got %d entries", len(entries))
+	}
+}
+
+func TestPlan_RejectsFileDirectoryConflicts(t *testing.T) {
+	destDir := t.TempDir()
+
+	files := []File{
+		{Path: "a", Content: []byte("a\n")},
+		{Path: "b.txt", Content: []byte("b\n")},
+		{Path: "a/sub/c.txt", Content: []byte("c\n")},
+	}
+
+	_, err := Plan(files, destDir, PolicyNever)
+	if err == nil || !strings.Contains(err.Error(), "path is both a file and a directory: a (parent of a/sub/c.txt)") {
+		t.Errorf("Expected file and directory conflict error, got: %v", err)
+	}
+
+	// A skipped placeholder is not written, so it doesn't conflict
+	files[0].Placeholder = true
+	if _, err := Plan(files, destDir, PolicyNever); err != nil {
+		t.Errorf("Plan failed: %v", err)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Policy
+		wantErr  bool
+	}{
+		{"", PolicyNever, false},
+		{"never", PolicyNever, false},
+		{"skip", PolicySkip, false},
+		{"ALWAYS", PolicyAlways, false},
+		{"sometimes", PolicyNever, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParsePolicy(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}